@@ -0,0 +1,166 @@
+package followparser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// Backfill reads a whole log file in parallel byte ranges aligned to newlines.
+// Each range is aggregated into its own A and the results are merged by Reduce in file order.
+// Callback, MaxReadSize and Workers of the embedded Parser are not used.
+type Backfill[A any] struct {
+	Parser
+	// Chunks is the number of ranges processed concurrently. runtime.NumCPU() is used if zero.
+	Chunks int
+	// New returns an empty aggregation for a range. The zero value of A is used if nil.
+	New func() A
+	// Parse aggregates a line into acc. Returning ErrStop stops the backfill after the line,
+	// the ranges after it are discarded and read again in the next run.
+	Parse func(acc *A, b []byte) error
+	// Reduce merges two aggregations. a precedes b in the file.
+	Reduce func(a, b A) A
+}
+
+// Run processes logFile from the beginning and sets the posfile to the end of the last complete line.
+func (bf *Backfill[A]) Run(posFileName, logFile string) (A, Parsed, error) {
+	var acc A
+	if bf.Parse == nil || bf.Reduce == nil {
+		return acc, Parsed{}, fmt.Errorf("failed to backfill :Parse and Reduce are required")
+	}
+	bf.setDefaults(logFile)
+	bf.posFile = newPosFile(bf.posFilePath(posFileName))
+	chunks := bf.Chunks
+	if chunks <= 0 {
+		chunks = runtime.NumCPU()
+	}
+
+	fstat, err := fileStat(logFile)
+	if err != nil {
+		return acc, Parsed{}, fmt.Errorf("failed to get inode from log file :%v", err)
+	}
+	f, err := os.Open(logFile)
+	if err != nil {
+		return acc, Parsed{}, fmt.Errorf("failed to open log file :%v", err)
+	}
+	defer f.Close()
+	if !bf.Silent {
+		log.Printf("Backfill start logFile:%s Size:%d Chunks:%d", logFile, fstat.Size, chunks)
+	}
+
+	bounds, err := chunkBounds(f, fstat.Size, chunks)
+	if err != nil {
+		return acc, Parsed{}, fmt.Errorf("failed to split log file :%v", err)
+	}
+
+	type result struct {
+		acc    A
+		rows   int
+		read   int64
+		errors int
+		err    error
+	}
+	results := make([]result, len(bounds)-1)
+	var wg sync.WaitGroup
+	for i := 0; i < len(bounds)-1; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := &results[i]
+			if bf.New != nil {
+				r.acc = bf.New()
+			}
+			p := &Parser{
+				StartBufSize: bf.StartBufSize,
+				MaxBufSize:   bf.MaxBufSize,
+				Silent:       true,
+				Callback:     &backfillCallback[A]{acc: &r.acc, parse: bf.Parse},
+			}
+			section := io.NewSectionReader(f, bounds[i], bounds[i+1]-bounds[i])
+			// the last range leaves a partial line for the next run
+			r.rows, r.read, r.err = p.scanFile(section, i == len(bounds)-2)
+			if r.err == io.EOF {
+				r.err = nil
+			}
+			r.errors = p.scanErrors
+		}(i)
+	}
+	wg.Wait()
+
+	parsed := Parsed{
+		FileName: logFile,
+		Size:     fstat.Size,
+	}
+	for i, r := range results {
+		if r.err != nil && r.err != ErrStop {
+			return acc, parsed, fmt.Errorf("something wrong in parse log :%v", r.err)
+		}
+		if i == 0 {
+			acc = r.acc
+		} else {
+			acc = bf.Reduce(acc, r.acc)
+		}
+		parsed.Rows += r.rows
+		parsed.Errors += r.errors
+		parsed.EndPos = bounds[i] + r.read
+		if r.err == ErrStop {
+			parsed.Interrupted = true
+			break
+		}
+	}
+
+	if err := bf.updatePos(parsed.EndPos, fstat); err != nil {
+		return acc, parsed, err
+	}
+	if !bf.Silent {
+		log.Printf("Backfill completed logFile:%s endPos:%d Rows:%d", logFile, parsed.EndPos, parsed.Rows)
+	}
+	return acc, parsed, nil
+}
+
+// chunkBounds splits [0, size) into at most n ranges that start at the head of a line
+func chunkBounds(f io.ReaderAt, size int64, n int) ([]int64, error) {
+	bounds := []int64{0}
+	buf := make([]byte, 64*1024)
+	for i := 1; i < n; i++ {
+		pos := max(size*int64(i)/int64(n), bounds[len(bounds)-1])
+		// find the head of the next line
+		for pos < size {
+			nRead, err := f.ReadAt(buf[:min(int64(len(buf)), size-pos)], pos)
+			if idx := bytes.IndexByte(buf[:nRead], '\n'); idx >= 0 {
+				pos += int64(idx + 1)
+				break
+			}
+			pos += int64(nRead)
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			if nRead == 0 {
+				pos = size
+			}
+		}
+		if pos >= size {
+			break
+		}
+		if pos > bounds[len(bounds)-1] {
+			bounds = append(bounds, pos)
+		}
+	}
+	return append(bounds, size), nil
+}
+
+type backfillCallback[A any] struct {
+	acc   *A
+	parse func(acc *A, b []byte) error
+}
+
+func (c *backfillCallback[A]) Parse(b []byte) error {
+	return c.parse(c.acc, b)
+}
+
+func (c *backfillCallback[A]) Finish(_ float64) {
+}
@@ -0,0 +1,140 @@
+package followparser
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackfill(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	content := ""
+	sum := 0
+	for i := 0; i < 1000; i++ {
+		content += fmt.Sprintf("%d\n", i)
+		sum += i
+	}
+	// the partial line is left for the next run
+	if err := os.WriteFile(logFileName, []byte(content+"1000"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	bf := &Backfill[[]int]{
+		Parser: Parser{WorkDir: tmpdir, Silent: true},
+		Chunks: 7,
+		Parse: func(acc *[]int, b []byte) error {
+			n := 0
+			if _, err := fmt.Sscanf(string(b), "%d", &n); err != nil {
+				return err
+			}
+			*acc = append(*acc, n)
+			return nil
+		},
+		Reduce: func(a, b []int) []int {
+			return append(a, b...)
+		},
+	}
+	acc, parsed, err := bf.Run("backfillPos", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(acc) != 1000 {
+		t.Fatalf("acc must have 1000 values %d", len(acc))
+	}
+	total := 0
+	for i, n := range acc {
+		if n != i {
+			t.Fatalf("acc must be in file order: acc[%d]=%d", i, n)
+		}
+		total += n
+	}
+	if total != sum {
+		t.Errorf("sum must be %d: %d", sum, total)
+	}
+	if parsed.Rows != 1000 || parsed.EndPos != int64(len(content)) {
+		t.Fatalf("unexpected parsed %v", parsed)
+	}
+
+	// the next run continues from the end of the backfill
+	f, err := os.OpenFile(logFileName, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("\n1001\n")
+	f.Close()
+	parser := &testParser{buf: bytes.NewBufferString("")}
+	fp := &Parser{WorkDir: tmpdir, Callback: parser, Silent: true}
+	if _, err := fp.Parse("backfillPos", logFileName); err != nil {
+		t.Fatal(err)
+	}
+	if out := parser.Slurp().String(); out != "1000\n1001\n" {
+		t.Fatalf("read '%s' not match expect '%s'", out, "1000\n1001\n")
+	}
+}
+
+func TestBackfillStop(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	content := ""
+	for i := 0; i < 1000; i++ {
+		content += fmt.Sprintf("%d\n", i)
+	}
+	if err := os.WriteFile(logFileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	bf := &Backfill[int]{
+		Parser: Parser{WorkDir: tmpdir, Silent: true},
+		Chunks: 7,
+		Parse: func(acc *int, b []byte) error {
+			*acc++
+			if string(b) == "499" {
+				return ErrStop
+			}
+			return nil
+		},
+	}
+	if _, _, err := bf.Run("backfillPos", logFileName); err == nil {
+		t.Fatal("Run without Reduce should fail")
+	}
+	bf.Reduce = func(a, b int) int {
+		return a + b
+	}
+	acc, parsed, err := bf.Run("backfillPos", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the lines after 499 are read in the next run
+	end := int64(strings.Index(content, "500\n"))
+	if acc != 500 || parsed.Rows != 500 || parsed.EndPos != end || !parsed.Interrupted {
+		t.Fatalf("unexpected acc %d parsed %v", acc, parsed)
+	}
+	parser := &testParser{buf: bytes.NewBufferString("")}
+	fp := &Parser{WorkDir: tmpdir, Callback: parser, Silent: true}
+	if _, err := fp.Parse("backfillPos", logFileName); err != nil {
+		t.Fatal(err)
+	}
+	if out := parser.Slurp().String(); out != content[end:] {
+		t.Fatalf("read '%s' not match expect '%s'", out, content[end:])
+	}
+}
+
+func TestChunkBounds(t *testing.T) {
+	data := []byte("aaaa\nbb\ncccccccccc\nd\n")
+	bounds, err := chunkBounds(bytes.NewReader(data), int64(len(data)), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(bounds) != "[0 8 19 21]" {
+		t.Fatalf("unexpected bounds %v", bounds)
+	}
+	for _, b := range bounds[1 : len(bounds)-1] {
+		if data[b-1] != '\n' {
+			t.Errorf("bound %d must be the head of a line", b)
+		}
+	}
+}
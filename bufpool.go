@@ -0,0 +1,173 @@
+package followparser
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrBufferLimit is returned when a read buffer cannot grow within the buffer pool limit
+var ErrBufferLimit = errors.New("reader: buffer pool limit reached")
+
+// maxIdleBuffers is the number of idle buffers kept for each size
+const maxIdleBuffers = 4
+
+// maxIdleBytes is the total size of idle buffers kept
+const maxIdleBytes = 16 * 1024 * 1024
+
+// bufferPool shares read buffers across Parsers.
+// used counts the bytes of buffers in use and idle, it is kept under limit if limit is set.
+type bufferPool struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	limit   int64
+	used    int64
+	idle    map[int][][]byte
+	holders int
+	growers int
+	// idleBytes is the total size of idle buffers, kept under maxIdleBytes
+	idleBytes int64
+}
+
+var defaultBufferPool = newBufferPool(0)
+
+// SetBufferPoolLimit sets the total size of read buffers shared by all Parsers in the process.
+// When the limit is reached, a Parser waits until other Parsers release their buffers.
+// If a buffer cannot grow because all other Parsers are waiting too, the scan fails with ErrBufferLimit.
+// Zero means no limit.
+func SetBufferPoolLimit(limit int64) {
+	defaultBufferPool.setLimit(limit)
+}
+
+func newBufferPool(limit int64) *bufferPool {
+	p := &bufferPool{
+		limit: limit,
+		idle:  make(map[int][][]byte),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+func (p *bufferPool) setLimit(limit int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.limit = limit
+	p.cond.Broadcast()
+}
+
+// get returns a buffer of size. It waits while the limit is reached and other buffers are in use.
+func (p *bufferPool) get(size int) []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if b := p.popIdle(size); b != nil {
+		p.holders++
+		return b
+	}
+	for !p.fits(int64(size)) && p.holders > 0 {
+		p.cond.Wait()
+		if b := p.popIdle(size); b != nil {
+			p.holders++
+			return b
+		}
+	}
+	p.used += int64(size)
+	p.holders++
+	return make([]byte, size)
+}
+
+// grow returns a buffer of size with the contents of old and releases old.
+// old is not counted against the limit as it is released right after copying.
+func (p *bufferPool) grow(old []byte, size int) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if b := p.popIdle(size); b != nil {
+		copy(b, old)
+		p.release(old)
+		return b, nil
+	}
+	for !p.fits(int64(size - len(old))) {
+		if p.growers+1 >= p.holders {
+			// nobody can release a buffer
+			return nil, ErrBufferLimit
+		}
+		p.growers++
+		p.cond.Wait()
+		p.growers--
+	}
+	p.used += int64(size)
+	b := make([]byte, size)
+	copy(b, old)
+	p.release(old)
+	return b, nil
+}
+
+// getSpare returns a buffer of size without waiting, or nil if it does not fit in the limit.
+// A spare buffer is used along with a buffer from get and is not counted as a holder,
+// so the holders stay the number of Parsers that can release buffers.
+func (p *bufferPool) getSpare(size int) []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if b := p.popIdle(size); b != nil {
+		return b
+	}
+	if !p.fits(int64(size)) {
+		return nil
+	}
+	p.used += int64(size)
+	return make([]byte, size)
+}
+
+// putSpare releases a buffer returned by getSpare
+func (p *bufferPool) putSpare(b []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.release(b)
+}
+
+// put releases a buffer returned by get or grow
+func (p *bufferPool) put(b []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.holders--
+	p.release(b)
+}
+
+func (p *bufferPool) release(b []byte) {
+	overLimit := p.limit > 0 && p.used > p.limit
+	if !overLimit && len(p.idle[len(b)]) < maxIdleBuffers && p.idleBytes+int64(len(b)) <= maxIdleBytes {
+		p.idle[len(b)] = append(p.idle[len(b)], b)
+		p.idleBytes += int64(len(b))
+	} else {
+		p.used -= int64(len(b))
+	}
+	p.cond.Broadcast()
+}
+
+func (p *bufferPool) popIdle(size int) []byte {
+	bufs := p.idle[size]
+	if len(bufs) == 0 {
+		return nil
+	}
+	b := bufs[len(bufs)-1]
+	p.idle[size] = bufs[:len(bufs)-1]
+	p.idleBytes -= int64(size)
+	return b
+}
+
+// fits reports whether size can be allocated within the limit, dropping idle buffers if needed
+func (p *bufferPool) fits(size int64) bool {
+	if p.limit <= 0 {
+		return true
+	}
+	for s, bufs := range p.idle {
+		if p.used+size <= p.limit {
+			break
+		}
+		for len(bufs) > 0 && p.used+size > p.limit {
+			p.used -= int64(s)
+			p.idleBytes -= int64(s)
+			bufs = bufs[:len(bufs)-1]
+		}
+		p.idle[s] = bufs
+	}
+	return p.used+size <= p.limit
+}
@@ -0,0 +1,119 @@
+package followparser
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBufferPoolReuse(t *testing.T) {
+	p := newBufferPool(0)
+	b := p.get(100)
+	b[0] = 'x'
+	p.put(b)
+	b2 := p.get(100)
+	if &b2[0] != &b[0] {
+		t.Errorf("buffer must be reused")
+	}
+	g, err := p.grow(b2, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g) != 200 || g[0] != 'x' {
+		t.Errorf("grown buffer must keep the contents")
+	}
+	p.put(g)
+	if p.holders != 0 || p.used != 300 {
+		t.Errorf("unexpected pool state holders:%d used:%d", p.holders, p.used)
+	}
+}
+
+func TestBufferPoolLimitWait(t *testing.T) {
+	p := newBufferPool(150)
+	b := p.get(100)
+	got := make(chan []byte)
+	go func() {
+		got <- p.get(100)
+	}()
+	select {
+	case <-got:
+		t.Fatal("get must wait for the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+	p.put(b)
+	select {
+	case b2 := <-got:
+		if &b2[0] != &b[0] {
+			t.Errorf("released buffer must be reused")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("get must be woken up by put")
+	}
+}
+
+func TestBufferPoolLimitGrow(t *testing.T) {
+	p := newBufferPool(250)
+	a := p.get(100)
+	b := p.get(100)
+	grown := make(chan error)
+	go func() {
+		_, err := p.grow(a, 200)
+		grown <- err
+	}()
+	select {
+	case <-grown:
+		t.Fatal("grow must wait for other buffers")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// the other holder can not grow either, it degrades
+	if _, err := p.grow(b, 200); !errors.Is(err, ErrBufferLimit) {
+		t.Fatalf("grow must fail with ErrBufferLimit: %v", err)
+	}
+	p.put(b)
+	select {
+	case err := <-grown:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("grow must be woken up by put")
+	}
+	if p.used > 250 {
+		t.Errorf("used %d must be within the limit", p.used)
+	}
+}
+
+func TestBufferPoolGrowReuse(t *testing.T) {
+	p := newBufferPool(0)
+	b := p.get(100)
+	g, err := p.grow(b, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.put(g)
+
+	// the grown buffer is reused by the next grow
+	b = p.get(100)
+	b[0] = 'y'
+	g2, err := p.grow(b, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &g2[0] != &g[0] || g2[0] != 'y' {
+		t.Errorf("idle buffer must be reused with the contents")
+	}
+	p.put(g2)
+	if p.used != 300 || p.idleBytes != 300 {
+		t.Errorf("unexpected pool state used:%d idle:%d", p.used, p.idleBytes)
+	}
+
+	// idle buffers are bounded without a limit
+	size := maxIdleBytes / 3
+	bufs := [][]byte{p.get(size), p.get(size), p.get(size), p.get(size)}
+	for _, b := range bufs {
+		p.put(b)
+	}
+	if p.idleBytes > maxIdleBytes || len(p.idle[size]) != 2 {
+		t.Errorf("idle buffers must be bounded idle:%d buffers:%d", p.idleBytes, len(p.idle[size]))
+	}
+}
@@ -0,0 +1,68 @@
+package followparser
+
+import (
+	"log"
+	"time"
+)
+
+// Flusher is implemented by a Callback that buffers its output.
+// Flush is called before the posfile is written at a checkpoint, so the posfile never gets ahead of the output.
+// The checkpoint is skipped if Flush fails.
+type Flusher interface {
+	Flush() error
+}
+
+// checkpointState is the position of the last checkpoint in the current scan
+type checkpointState struct {
+	fstat *fStat
+	read  int64
+	rows  int
+	time  time.Time
+}
+
+// startCheckpoint resets the checkpoint state for a scan of the file of fstat
+func (parser *Parser) startCheckpoint(fstat *fStat) {
+	parser.checkpointState = checkpointState{fstat: fstat, time: time.Now()}
+}
+
+// checkpointEnabled reports whether any checkpoint interval is set and the posfile is written automatically
+func (parser *Parser) checkpointEnabled() bool {
+	if parser.NoAutoCommitPosFile || parser.posFile == nil {
+		return false
+	}
+	return parser.CheckpointBytes > 0 || parser.CheckpointLines > 0 || parser.CheckpointInterval > 0
+}
+
+// checkpoint writes the posfile if a checkpoint is due. read and rows are the bytes and lines scanned
+// at a line boundary, with workers the acknowledged watermark is used instead.
+func (parser *Parser) checkpoint(pool *workerPool, read int64, rows int) {
+	if !parser.checkpointEnabled() {
+		return
+	}
+	if pool != nil {
+		rows, read = pool.position()
+	}
+	cp := &parser.checkpointState
+	if read == cp.read {
+		return
+	}
+	due := (parser.CheckpointBytes > 0 && read-cp.read >= parser.CheckpointBytes) ||
+		(parser.CheckpointLines > 0 && rows-cp.rows >= parser.CheckpointLines) ||
+		(parser.CheckpointInterval > 0 && time.Since(cp.time) >= parser.CheckpointInterval)
+	if !due {
+		return
+	}
+	if f, ok := parser.Callback.(Flusher); ok {
+		if err := f.Flush(); err != nil {
+			log.Printf("Failed to flush callback, skip checkpoint :%v", err)
+			return
+		}
+	}
+	if err := parser.updatePos(parser.scanBase+read, cp.fstat); err != nil {
+		log.Printf("Failed to checkpoint :%v", err)
+		return
+	}
+	cp.read = read
+	cp.rows = rows
+	cp.time = time.Now()
+}
@@ -0,0 +1,130 @@
+package followparser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// flushingParser counts the flushes and records the posfile position seen at line peekAt
+type flushingParser struct {
+	mu      sync.Mutex
+	pf      *posFile
+	peekAt  int
+	lines   int
+	flushes int
+	peekPos int64
+	failing bool
+}
+
+func (p *flushingParser) Parse(b []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lines++
+	if p.lines == p.peekAt {
+		pos, _, _, err := p.pf.read()
+		if err != nil {
+			return err
+		}
+		p.peekPos = pos
+	}
+	return nil
+}
+
+func (p *flushingParser) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failing {
+		return errors.New("flush failed")
+	}
+	p.flushes++
+	return nil
+}
+
+func (p *flushingParser) Finish(_ float64) {
+}
+
+func writeCheckpointLog(t *testing.T, logFileName string, lines int) string {
+	t.Helper()
+	content := ""
+	for i := 0; i < lines; i++ {
+		content += fmt.Sprintf("msg msg %08d\n", i)
+	}
+	if err := os.WriteFile(logFileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestParseCheckpointLines(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	content := writeCheckpointLog(t, logFileName, 1000)
+	lineLen := int64(len(content) / 1000)
+
+	fp := &Parser{WorkDir: tmpdir, Silent: true, StartBufSize: 256, CheckpointLines: 100}
+	cb := &flushingParser{pf: newPosFile(fp.posFilePath("logPosCheckpoint")), peekAt: 500}
+	fp.Callback = cb
+	r, err := fp.Parse("logPosCheckpoint", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 1 || r[0].Rows != 1000 || r[0].EndPos != int64(len(content)) {
+		t.Fatalf("unexpected result %v", r)
+	}
+	if cb.flushes < 9 {
+		t.Fatalf("flushes %d not match expect at least 9", cb.flushes)
+	}
+	// the posfile is written at a line boundary that has been flushed
+	if cb.peekPos < 400*lineLen || cb.peekPos > 500*lineLen || cb.peekPos%lineLen != 0 {
+		t.Fatalf("checkpoint pos %d is not a flushed line boundary", cb.peekPos)
+	}
+}
+
+func TestParseCheckpointBytesWorkers(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	content := writeCheckpointLog(t, logFileName, 1000)
+	lineLen := int64(len(content) / 1000)
+
+	fp := &Parser{WorkDir: tmpdir, Silent: true, StartBufSize: 256, Workers: 4, CheckpointBytes: 1024}
+	cb := &flushingParser{pf: newPosFile(fp.posFilePath("logPosCheckpointWorkers")), peekAt: 500}
+	fp.Callback = cb
+	r, err := fp.Parse("logPosCheckpointWorkers", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 1 || r[0].Rows != 1000 || r[0].EndPos != int64(len(content)) {
+		t.Fatalf("unexpected result %v", r)
+	}
+	// the checkpoint never passes the lines acknowledged by the workers
+	if cb.peekPos == 0 || cb.peekPos > 500*lineLen || cb.peekPos%lineLen != 0 {
+		t.Fatalf("checkpoint pos %d is not an acknowledged line boundary", cb.peekPos)
+	}
+}
+
+func TestParseCheckpointFlushError(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	content := writeCheckpointLog(t, logFileName, 1000)
+
+	fp := &Parser{WorkDir: tmpdir, Silent: true, StartBufSize: 256, CheckpointLines: 100}
+	cb := &flushingParser{pf: newPosFile(fp.posFilePath("logPosCheckpointFail")), peekAt: 500, failing: true}
+	fp.Callback = cb
+	if _, err := fp.Parse("logPosCheckpointFail", logFileName); err != nil {
+		t.Fatal(err)
+	}
+	if cb.peekPos != 0 {
+		t.Fatalf("checkpoint should be skipped when flush fails: %d", cb.peekPos)
+	}
+	pos, _, _, err := cb.pf.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != int64(len(content)) {
+		t.Fatalf("pos %d not match expect %d", pos, len(content))
+	}
+}
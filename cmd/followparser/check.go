@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// check-log style exit codes
+const (
+	checkOK = iota
+	checkWarning
+	checkCritical
+	checkUnknown
+)
+
+var checkStatus = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// regexpsFlag is a repeatable flag of regexps
+type regexpsFlag []*regexp.Regexp
+
+func (f *regexpsFlag) String() string {
+	s := make([]string, 0, len(*f))
+	for _, re := range *f {
+		s = append(s, re.String())
+	}
+	return strings.Join(s, ",")
+}
+
+func (f *regexpsFlag) Set(s string) error {
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, re)
+	return nil
+}
+
+func matchAny(res []*regexp.Regexp, b []byte) bool {
+	for _, re := range res {
+		if re.Match(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCallback counts the lines matching the warning and critical patterns
+type checkCallback struct {
+	warning   []*regexp.Regexp
+	critical  []*regexp.Regexp
+	exclude   []*regexp.Regexp
+	maxLines  int
+	warnings  int
+	criticals int
+	lines     []string
+	duration  float64
+}
+
+func (c *checkCallback) Parse(b []byte) error {
+	if matchAny(c.exclude, b) {
+		return nil
+	}
+	if matchAny(c.critical, b) {
+		c.criticals++
+	} else if matchAny(c.warning, b) {
+		c.warnings++
+	} else {
+		return nil
+	}
+	if len(c.lines) < c.maxLines {
+		c.lines = append(c.lines, string(b))
+	}
+	return nil
+}
+
+func (c *checkCallback) Finish(duration float64) {
+	c.duration = duration
+}
+
+// thresholdStatus returns status if count matches over duration seconds exceed a threshold.
+// A threshold below zero is not checked. Rates are not checked without a duration.
+func thresholdStatus(count int, duration float64, over int, rate float64, status int) int {
+	if over >= 0 && count > over {
+		return status
+	}
+	if rate >= 0 && duration > 0 && float64(count)/duration > rate {
+		return status
+	}
+	return checkOK
+}
+
+func runCheck(args []string, stdout, stderr io.Writer) int {
+	var pf parserFlags
+	cb := &checkCallback{}
+	fs := newFlagSet("check", stderr, &pf)
+	fs.Var((*regexpsFlag)(&cb.warning), "warning-pattern", "regexp of warning lines, can be repeated")
+	fs.Var((*regexpsFlag)(&cb.critical), "critical-pattern", "regexp of critical lines, can be repeated")
+	fs.Var((*regexpsFlag)(&cb.exclude), "exclude", "regexp of lines to ignore, can be repeated")
+	warningOver := fs.Int("warning-over", 0, "warning if more than this many warning lines match, not checked with --warning-rate unless given")
+	criticalOver := fs.Int("critical-over", 0, "critical if more than this many critical lines match, not checked with --critical-rate unless given")
+	warningRate := fs.Float64("warning-rate", -1, "warning if more than this many warning lines per second match since the last run")
+	criticalRate := fs.Float64("critical-rate", -1, "critical if more than this many critical lines per second match since the last run")
+	fs.IntVar(&cb.maxLines, "max-lines", 10, "number of matched lines to print")
+	logFile, ok := parseArgs(fs, &pf, args)
+	if !ok {
+		return checkUnknown
+	}
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	if *warningRate >= 0 && !given["warning-over"] {
+		*warningOver = -1
+	}
+	if *criticalRate >= 0 && !given["critical-over"] {
+		*criticalOver = -1
+	}
+	if len(cb.warning) == 0 && len(cb.critical) == 0 {
+		fmt.Fprintf(stdout, "LOG UNKNOWN: --warning-pattern or --critical-pattern is required\n")
+		return checkUnknown
+	}
+	parser := pf.parser()
+	parser.Callback = cb
+	if _, err := parser.Parse(pf.pos, logFile); err != nil {
+		fmt.Fprintf(stdout, "LOG UNKNOWN: %v\n", err)
+		return checkUnknown
+	}
+
+	status := checkOK
+	if len(cb.critical) > 0 {
+		status = thresholdStatus(cb.criticals, cb.duration, *criticalOver, *criticalRate, checkCritical)
+	}
+	if status == checkOK && len(cb.warning) > 0 {
+		status = thresholdStatus(cb.warnings, cb.duration, *warningOver, *warningRate, checkWarning)
+	}
+	fmt.Fprintf(stdout, "LOG %s: %d warning and %d critical lines matched\n", checkStatus[status], cb.warnings, cb.criticals)
+	for _, line := range cb.lines {
+		fmt.Fprintln(stdout, line)
+	}
+	return status
+}
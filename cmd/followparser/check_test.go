@@ -0,0 +1,64 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRunCheck(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	flags := []string{"check", "--pos", "checkPos", "--workdir", tmpdir,
+		"--warning-pattern", "WARN", "--critical-pattern", "FATAL|PANIC", "--exclude", "ignore me"}
+
+	writeFile(t, logFileName, "INFO ok\nWARN disk\nFATAL ignore me\n")
+	code, stdout, _ := runCmd(t, append(flags, logFileName)...)
+	if code != checkWarning || stdout != "LOG WARNING: 1 warning and 0 critical lines matched\nWARN disk\n" {
+		t.Fatalf("exit %d unexpected check '%s'", code, stdout)
+	}
+
+	// only the new lines are checked
+	appendLog(t, logFileName, "PANIC oops\nWARN disk\n")
+	code, stdout, _ = runCmd(t, append(flags, "--max-lines", "1", logFileName)...)
+	if code != checkCritical || stdout != "LOG CRITICAL: 1 warning and 1 critical lines matched\nPANIC oops\n" {
+		t.Fatalf("exit %d unexpected check '%s'", code, stdout)
+	}
+
+	appendLog(t, logFileName, "WARN disk\nWARN disk\n")
+	code, stdout, _ = runCmd(t, append(flags, "--warning-over", "2", logFileName)...)
+	if code != checkOK || stdout != "LOG OK: 2 warning and 0 critical lines matched\nWARN disk\nWARN disk\n" {
+		t.Fatalf("exit %d unexpected check '%s'", code, stdout)
+	}
+
+	if code, _, _ := runCmd(t, "check", "--pos", "checkPos", "--workdir", tmpdir, logFileName); code != checkUnknown {
+		t.Fatalf("exit %d not match expect %d", code, checkUnknown)
+	}
+	if code, _, _ := runCmd(t, append(flags, "--warning-pattern", "(", logFileName)...); code != checkUnknown {
+		t.Fatalf("exit %d not match expect %d", code, checkUnknown)
+	}
+	if code, _, _ := runCmd(t, append(flags, filepath.Join(tmpdir, "nosuch"))...); code != checkUnknown {
+		t.Fatalf("exit %d not match expect %d", code, checkUnknown)
+	}
+}
+
+func TestThresholdStatus(t *testing.T) {
+	tests := []struct {
+		count    int
+		duration float64
+		over     int
+		rate     float64
+		expect   int
+	}{
+		{0, 60, 0, -1, checkOK},
+		{1, 60, 0, -1, checkWarning},
+		{10, 60, -1, 0.5, checkOK},
+		{31, 60, -1, 0.5, checkWarning},
+		// no rate on the first run
+		{31, 0, -1, 0.5, checkOK},
+	}
+	for _, tt := range tests {
+		if s := thresholdStatus(tt.count, tt.duration, tt.over, tt.rate, checkWarning); s != tt.expect {
+			t.Fatalf("%+v status %d not match expect %d", tt, s, tt.expect)
+		}
+	}
+}
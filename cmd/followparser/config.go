@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/monitoring-forge/followparser"
+	"gopkg.in/yaml.v3"
+)
+
+// runConfig is the config file of the run command
+type runConfig struct {
+	// WorkDir is the directory of posfiles
+	WorkDir string `yaml:"workdir"`
+	// MaxDuration is shared by all logs, the logs not read in time are read in the next run
+	MaxDuration time.Duration `yaml:"max_duration"`
+	// RateLimit is shared by all logs
+	RateLimit struct {
+		BytesPerSec float64 `yaml:"bytes_per_sec"`
+		LinesPerSec float64 `yaml:"lines_per_sec"`
+	} `yaml:"rate_limit"`
+	// BufferLimit is the total size of read buffers
+	BufferLimit int64       `yaml:"buffer_limit"`
+	Logs        []logConfig `yaml:"logs"`
+}
+
+// logConfig is a followed log
+type logConfig struct {
+	// Name is used in messages, the posfile name or the path by default
+	Name string `yaml:"name"`
+	// Path is a log file or a glob. With a glob, the base name of each file is appended to the posfile name.
+	Path        string        `yaml:"path"`
+	Pos         string        `yaml:"pos"`
+	ArchiveDir  string        `yaml:"archive_dir"`
+	MaxReadSize int64         `yaml:"max_read_size"`
+	Decoder     decoderConfig `yaml:"decoder"`
+	Filters     filterConfig  `yaml:"filters"`
+	Output      outputConfig  `yaml:"output"`
+}
+
+type decoderConfig struct {
+	// Type is raw, ltsv, json, nginx, apache or regex
+	Type string `yaml:"type"`
+	// Format is the log_format of nginx or the LogFormat of apache
+	Format string `yaml:"format"`
+	// Pattern is the regexp with named groups of regex
+	Pattern string `yaml:"pattern"`
+}
+
+type filterConfig struct {
+	// Include selects the lines matching any of the regexps
+	Include []string `yaml:"include"`
+	// Exclude drops the lines matching any of the regexps
+	Exclude []string `yaml:"exclude"`
+	// Fields selects the records whose fields match all of the regexps
+	Fields  map[string]string `yaml:"fields"`
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+	fields  []fieldFilter
+}
+
+type fieldFilter struct {
+	name string
+	re   *regexp.Regexp
+}
+
+type outputConfig struct {
+	// Type is stdout, file or exec
+	Type string `yaml:"type"`
+	// Path is the file the lines are appended to
+	Path string `yaml:"path"`
+	// Command is run with the lines on stdin if there are any
+	Command []string `yaml:"command"`
+	// Fields writes these fields separated by tabs instead of the line
+	Fields []string `yaml:"fields"`
+}
+
+// loadConfig reads and validates a config file
+func loadConfig(name string) (*runConfig, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config :%v", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	var cfg runConfig
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config :%v", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// validate checks the config and compiles the filters
+func (cfg *runConfig) validate() error {
+	var errs []error
+	if cfg.MaxDuration < 0 || cfg.RateLimit.BytesPerSec < 0 || cfg.RateLimit.LinesPerSec < 0 || cfg.BufferLimit < 0 {
+		errs = append(errs, errors.New("max_duration, rate_limit and buffer_limit must not be negative"))
+	}
+	if len(cfg.Logs) == 0 {
+		errs = append(errs, errors.New("no logs"))
+	}
+	poses := map[string]bool{}
+	for i := range cfg.Logs {
+		l := &cfg.Logs[i]
+		if l.Name == "" {
+			l.Name = defaultString(l.Pos, l.Path)
+		}
+		for _, err := range l.validate() {
+			errs = append(errs, fmt.Errorf("logs[%d] %s: %v", i, l.Name, err))
+		}
+		if l.Pos != "" && poses[l.Pos] {
+			errs = append(errs, fmt.Errorf("logs[%d] %s: pos %q is used twice", i, l.Name, l.Pos))
+		}
+		poses[l.Pos] = true
+	}
+	return errors.Join(errs...)
+}
+
+func (l *logConfig) validate() []error {
+	var errs []error
+	if l.Path == "" {
+		errs = append(errs, errors.New("path is required"))
+	}
+	if l.Pos == "" {
+		errs = append(errs, errors.New("pos is required"))
+	}
+	if l.MaxReadSize < 0 {
+		errs = append(errs, errors.New("max_read_size must not be negative"))
+	}
+
+	// the fields the decoder provides, nil if any field may be looked up
+	var known []string
+	switch l.Decoder.Type {
+	case "", "raw":
+		l.Decoder.Type = "raw"
+		known = []string{}
+	case "ltsv", "json":
+	case "nginx", "apache", "regex":
+		format := l.Decoder.Format
+		if l.Decoder.Type == "regex" {
+			format = l.Decoder.Pattern
+		}
+		cb, err := recordCallback(l.Decoder.Type, format, nil, nil, nil)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+		switch cb := cb.(type) {
+		case *followparser.LogFormatCallback:
+			known = cb.Format.Fields()
+		case *followparser.RegexpCallback:
+			known = []string{}
+			for _, name := range cb.Regexp.SubexpNames() {
+				if name != "" {
+					known = append(known, name)
+				}
+			}
+			if len(known) == 0 {
+				errs = append(errs, errors.New("pattern has no named groups"))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown decoder %q", l.Decoder.Type))
+	}
+	if l.Decoder.Pattern != "" && l.Decoder.Type != "regex" {
+		errs = append(errs, errors.New("pattern is only for the regex decoder"))
+	}
+	if l.Decoder.Format != "" && l.Decoder.Type != "nginx" && l.Decoder.Type != "apache" {
+		errs = append(errs, errors.New("format is only for the nginx and apache decoders"))
+	}
+
+	var err error
+	if l.Filters.include, err = compileRegexps(l.Filters.Include); err != nil {
+		errs = append(errs, fmt.Errorf("invalid include :%v", err))
+	}
+	if l.Filters.exclude, err = compileRegexps(l.Filters.Exclude); err != nil {
+		errs = append(errs, fmt.Errorf("invalid exclude :%v", err))
+	}
+	// in a fixed order for the messages
+	names := make([]string, 0, len(l.Filters.Fields))
+	for name := range l.Filters.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	l.Filters.fields = nil
+	for _, name := range names {
+		re, err := regexp.Compile(l.Filters.Fields[name])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid filter of field %s :%v", name, err))
+			continue
+		}
+		l.Filters.fields = append(l.Filters.fields, fieldFilter{name: name, re: re})
+	}
+	for _, name := range append(names, l.Output.Fields...) {
+		if known != nil && !slices.Contains(known, name) {
+			errs = append(errs, fmt.Errorf("decoder %s has no field %q", l.Decoder.Type, name))
+		}
+	}
+
+	switch l.Output.Type {
+	case "", "stdout":
+		l.Output.Type = "stdout"
+	case "file":
+		if l.Output.Path == "" {
+			errs = append(errs, errors.New("path of the file output is required"))
+		}
+	case "exec":
+		if len(l.Output.Command) == 0 {
+			errs = append(errs, errors.New("command of the exec output is required"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown output %q", l.Output.Type))
+	}
+	if l.Output.Path != "" && l.Output.Type != "file" {
+		errs = append(errs, errors.New("path is only for the file output"))
+	}
+	if len(l.Output.Command) > 0 && l.Output.Type != "exec" {
+		errs = append(errs, errors.New("command is only for the exec output"))
+	}
+	return errs
+}
+
+// lookupFields returns the fields the filters and the output look up
+func (l *logConfig) lookupFields() []string {
+	var fields []string
+	for _, f := range l.Filters.fields {
+		fields = append(fields, f.name)
+	}
+	for _, name := range l.Output.Fields {
+		if !slices.Contains(fields, name) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+func compileRegexps(ss []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(ss))
+	for _, s := range ss {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
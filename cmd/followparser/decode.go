@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/monitoring-forge/followparser"
+)
+
+// recordCallback returns a Callback that decodes lines in format and passes the records to handler.
+// fields are the fields the handler looks up, logFormat overrides the default format of nginx and apache
+// and is the pattern with named groups of regex.
+func recordCallback(format, logFormat string, fields []string, handler func(followparser.Record) error, onFinish func(float64)) (followparser.Callback, error) {
+	switch format {
+	case "ltsv":
+		return &followparser.LTSVCallback{
+			Labels:   fields,
+			Handler:  func(r *followparser.LTSVRecord) error { return handler(r) },
+			OnFinish: onFinish,
+		}, nil
+	case "json":
+		return &followparser.JSONCallback{
+			Fields:   fields,
+			Handler:  func(r *followparser.JSONRecord) error { return handler(r) },
+			OnFinish: onFinish,
+		}, nil
+	case "nginx", "apache":
+		var lf *followparser.LogFormat
+		var err error
+		if format == "nginx" {
+			lf, err = followparser.CompileNginxLogFormat(defaultString(logFormat, followparser.NginxCombinedFormat))
+		} else {
+			lf, err = followparser.CompileApacheLogFormat(defaultString(logFormat, followparser.ApacheCombinedFormat))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid log format :%v", err)
+		}
+		return &followparser.LogFormatCallback{
+			Format:   lf,
+			Handler:  func(r *followparser.LogFormatRecord) error { return handler(r) },
+			OnFinish: onFinish,
+		}, nil
+	case "regex":
+		re, err := regexp.Compile(logFormat)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex :%v", err)
+		}
+		return &followparser.RegexpCallback{
+			Regexp:   re,
+			Handler:  func(r *followparser.RegexpRecord) error { return handler(r) },
+			OnFinish: onFinish,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
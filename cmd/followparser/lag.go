@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+func runLag(args []string, stdout, stderr io.Writer) int {
+	var pf parserFlags
+	fs := newFlagSet("lag", stderr, &pf)
+	asJSON := fs.Bool("json", false, "print the lag as JSON")
+	logFile, ok := parseArgs(fs, &pf, args)
+	if !ok {
+		return 2
+	}
+	lag, err := pf.parser().Lag(pf.pos, logFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "followparser lag: %v\n", err)
+		return 1
+	}
+	if *asJSON {
+		err = json.NewEncoder(stdout).Encode(map[string]any{
+			"pending_bytes":    lag.PendingBytes,
+			"pending_lines":    lag.PendingLines,
+			"since_commit":     lag.SinceCommit,
+			"committed":        lag.Committed,
+			"rotation_pending": lag.RotationPending,
+			"rotated_file":     lag.RotatedFile,
+		})
+	} else {
+		_, err = fmt.Fprintf(stdout, "pending_bytes\t%d\npending_lines\t%d\nsince_commit\t%.0f\ncommitted\t%t\nrotation_pending\t%t\nrotated_file\t%s\n",
+			lag.PendingBytes, lag.PendingLines, lag.SinceCommit, lag.Committed, lag.RotationPending, lag.RotatedFile)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "followparser lag: %v\n", err)
+		return 1
+	}
+	return 0
+}
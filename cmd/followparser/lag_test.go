@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunLag(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	writeFile(t, logFileName, "line1\nline2\n")
+
+	code, stdout, stderr := runCmd(t, "lag", "--pos", "lagPos", "--workdir", tmpdir, logFileName)
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	expected := "pending_bytes\t12\npending_lines\t2\nsince_commit\t0\ncommitted\tfalse\nrotation_pending\tfalse\nrotated_file\t\n"
+	if stdout != expected {
+		t.Fatalf("read '%s' not match expect '%s'", stdout, expected)
+	}
+
+	code, stdout, stderr = runCmd(t, "lag", "--pos", "lagPos", "--workdir", tmpdir, "--json", logFileName)
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	var lag map[string]any
+	if err := json.Unmarshal([]byte(stdout), &lag); err != nil {
+		t.Fatal(err)
+	}
+	if lag["pending_bytes"] != float64(12) || lag["rotation_pending"] != false {
+		t.Fatalf("unexpected lag %v", lag)
+	}
+
+	if code, _, _ := runCmd(t, "lag", "--pos", "lagPos", "--workdir", tmpdir, filepath.Join(tmpdir, "nosuch")); code != 1 {
+		t.Fatalf("exit %d not match expect 1", code)
+	}
+}
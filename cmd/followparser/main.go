@@ -0,0 +1,104 @@
+// Command followparser follows log files with the same posfile semantics as the followparser library.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/monitoring-forge/followparser"
+)
+
+// command is a subcommand of followparser
+type command struct {
+	name  string
+	usage string
+	run   func(args []string, stdout, stderr io.Writer) int
+}
+
+var commands = []command{
+	{"read", "print the new lines since the last run and commit the posfile", runRead},
+	{"lag", "report how far a posfile is behind its log file", runLag},
+	{"show", "show the position in a posfile and the file it points to", runShow},
+	{"reset", "reset a posfile to the start or the end of the log file", runReset},
+	{"set", "set a posfile to a byte offset", runSet},
+	{"seek-time", "set a posfile to the first line at or after a time", runSeekTime},
+	{"replay", "print the lines of files in a byte or time range without a posfile", runReplay},
+	{"check", "count lines matching patterns since the last run, check-log style", runCheck},
+	{"metrics", "print access log metrics in the mackerel plugin format", runMetrics},
+	{"run", "follow the logs of a config file with shared limits", runRun},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	log.SetOutput(stderr)
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:], stdout, stderr)
+		}
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stdout)
+		return 0
+	}
+	fmt.Fprintf(stderr, "followparser: unknown command %q\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: followparser <command> [flags] <logfile>")
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.usage)
+	}
+}
+
+// parserFlags are the flags shared by the commands that use a posfile
+type parserFlags struct {
+	pos        string
+	workDir    string
+	archiveDir string
+}
+
+func newFlagSet(name string, stderr io.Writer, pf *parserFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet("followparser "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: followparser %s [flags] <logfile>\n", name)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&pf.pos, "pos", "", "posfile name (required)")
+	fs.StringVar(&pf.workDir, "workdir", "", "directory of posfiles (default: the temporary directory)")
+	fs.StringVar(&pf.archiveDir, "archive-dir", "", "directory of rotated files (default: the directory of the log file)")
+	return fs
+}
+
+// parseArgs parses the flags and returns the log file
+func parseArgs(fs *flag.FlagSet, pf *parserFlags, args []string) (string, bool) {
+	if err := fs.Parse(args); err != nil {
+		return "", false
+	}
+	if pf.pos == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return "", false
+	}
+	return fs.Arg(0), true
+}
+
+func (pf *parserFlags) parser() *followparser.Parser {
+	return &followparser.Parser{
+		WorkDir:    pf.workDir,
+		ArchiveDir: pf.archiveDir,
+		Silent:     true,
+	}
+}
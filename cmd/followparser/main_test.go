@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// runCmd runs followparser with args and returns the exit code, stdout and stderr
+func runCmd(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func writeFile(t *testing.T, name, s string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(s), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	if code, _, stderr := runCmd(t); code != 2 || !strings.Contains(stderr, "usage:") {
+		t.Fatalf("unexpected exit %d stderr '%s'", code, stderr)
+	}
+	if code, _, stderr := runCmd(t, "nosuch"); code != 2 || !strings.Contains(stderr, `unknown command "nosuch"`) {
+		t.Fatalf("unexpected exit %d stderr '%s'", code, stderr)
+	}
+	if code, stdout, _ := runCmd(t, "help"); code != 0 || !strings.Contains(stdout, "lag") {
+		t.Fatalf("unexpected exit %d stdout '%s'", code, stdout)
+	}
+	// --pos is required
+	if code, _, stderr := runCmd(t, "lag", "/dev/null"); code != 2 || !strings.Contains(stderr, "-pos") {
+		t.Fatalf("unexpected exit %d stderr '%s'", code, stderr)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monitoring-forge/followparser"
+)
+
+// now is replaced in tests
+var now = time.Now
+
+var statusClasses = []string{"1xx", "2xx", "3xx", "4xx", "5xx"}
+
+// accessMetrics aggregates access log records
+type accessMetrics struct {
+	statusField  string
+	latencyField string
+	latencyScale float64
+	total        int
+	statuses     [5]int
+	latencies    []float64
+	duration     float64
+}
+
+func (m *accessMetrics) add(r followparser.Record) error {
+	m.total++
+	if s, ok := r.Get(m.statusField); ok && len(s) == 3 && s[0] >= '1' && s[0] <= '5' {
+		m.statuses[s[0]-'1']++
+	}
+	if v, ok := r.Get(m.latencyField); ok {
+		// nginx logs "-" when there is no upstream
+		if f, err := strconv.ParseFloat(string(v), 64); err == nil {
+			m.latencies = append(m.latencies, f*m.latencyScale)
+		}
+	}
+	return nil
+}
+
+func (m *accessMetrics) finish(duration float64) {
+	m.duration = duration
+}
+
+// percentile returns the nearest rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(0, min(i, len(sorted)-1))]
+}
+
+// print writes the metrics in the mackerel plugin format
+func (m *accessMetrics) print(w io.Writer, prefix string, percentiles []float64) error {
+	epoch := now().Unix()
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s.access_num.total_count\t%d\t%d\n", prefix, m.total, epoch)
+	for i, class := range statusClasses {
+		fmt.Fprintf(&b, "%s.access_num.%s_count\t%d\t%d\n", prefix, class, m.statuses[i], epoch)
+	}
+	// the rate needs the time since the last run
+	if m.duration > 0 {
+		fmt.Fprintf(&b, "%s.access_rate.total_rate\t%f\t%d\n", prefix, float64(m.total)/m.duration, epoch)
+	}
+	if len(m.latencies) > 0 {
+		sort.Float64s(m.latencies)
+		sum := 0.0
+		for _, l := range m.latencies {
+			sum += l
+		}
+		fmt.Fprintf(&b, "%s.latency.average\t%f\t%d\n", prefix, sum/float64(len(m.latencies)), epoch)
+		for _, p := range percentiles {
+			fmt.Fprintf(&b, "%s.latency.%s_percentile\t%f\t%d\n", prefix, formatPercentile(p), percentile(m.latencies, p), epoch)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// prom adds the metrics to a Prometheus textfile
+func (m *accessMetrics) prom(p *followparser.PromTextfile, prefix string) {
+	for i, class := range statusClasses {
+		p.AddCounter(prefix+"_requests_total", "Requests by status class.", float64(m.statuses[i]), "status_class", class)
+	}
+	for _, l := range m.latencies {
+		p.Observe(prefix+"_request_duration_seconds", "Request time in seconds.", nil, l)
+	}
+}
+
+func formatPercentile(p float64) string {
+	return strings.ReplaceAll(strconv.FormatFloat(p, 'f', -1, 64), ".", "_")
+}
+
+type graphMetric struct {
+	Name    string `json:"name"`
+	Label   string `json:"label"`
+	Stacked bool   `json:"stacked"`
+}
+
+type graph struct {
+	Label   string        `json:"label"`
+	Unit    string        `json:"unit"`
+	Metrics []graphMetric `json:"metrics"`
+}
+
+// printMeta writes the graph definitions that mackerel-agent reads with MACKEREL_AGENT_PLUGIN_META=1
+func printMeta(w io.Writer, prefix string, percentiles []float64) error {
+	label := strings.ToUpper(prefix[:1]) + prefix[1:]
+	num := graph{Label: label + " Requests", Unit: "integer"}
+	num.Metrics = append(num.Metrics, graphMetric{Name: "total_count", Label: "Total"})
+	for _, class := range statusClasses {
+		num.Metrics = append(num.Metrics, graphMetric{Name: class + "_count", Label: class, Stacked: true})
+	}
+	latency := graph{Label: label + " Latency", Unit: "float"}
+	latency.Metrics = append(latency.Metrics, graphMetric{Name: "average", Label: "Average"})
+	for _, p := range percentiles {
+		name := formatPercentile(p)
+		latency.Metrics = append(latency.Metrics, graphMetric{Name: name + "_percentile", Label: name + " Percentile"})
+	}
+	graphs := map[string]graph{
+		prefix + ".access_num": num,
+		prefix + ".access_rate": {
+			Label:   label + " Request Rate",
+			Unit:    "float",
+			Metrics: []graphMetric{{Name: "total_rate", Label: "Requests per second"}},
+		},
+		prefix + ".latency": latency,
+	}
+	jb, err := json.Marshal(map[string]any{"graphs": graphs})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "# mackerel-agent-plugin\n%s\n", jb)
+	return err
+}
+
+func parsePercentiles(s string) ([]float64, error) {
+	var ps []float64
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		p, err := strconv.ParseFloat(f, 64)
+		if err != nil || p <= 0 || p > 100 {
+			return nil, fmt.Errorf("invalid percentile %q", f)
+		}
+		ps = append(ps, p)
+	}
+	return ps, nil
+}
+
+// defaultLatencyFields are the request time fields of each format and their scale to seconds
+var defaultLatencyFields = map[string]struct {
+	name  string
+	scale float64
+}{
+	"nginx":  {"request_time", 1},
+	"apache": {"request_time_us", 1e-6},
+	"ltsv":   {"reqtime", 1},
+	"json":   {"request_time", 1},
+}
+
+func runMetrics(args []string, stdout, stderr io.Writer) int {
+	var pf parserFlags
+	fs := newFlagSet("metrics", stderr, &pf)
+	format := fs.String("format", "nginx", "format of the log: nginx, apache, ltsv or json")
+	logFormat := fs.String("log-format", "", "nginx log_format or apache LogFormat (default: the combined format)")
+	statusField := fs.String("status-field", "status", "field of the status code")
+	latencyField := fs.String("latency-field", "", "field of the request time (default: request_time, request_time_us for apache, reqtime for ltsv)")
+	latencyScale := fs.Float64("latency-scale", 0, "multiplier to convert the request time to seconds (default: 1, 0.000001 for apache)")
+	prefix := fs.String("prefix", "accesslog", "prefix of the metric names")
+	percentilesFlag := fs.String("percentiles", "50,90,95,99", "comma separated latency percentiles")
+	promFile := fs.String("prom-file", "", "write a Prometheus textfile instead, counters are kept next to the posfile")
+	meta := fs.Bool("meta", os.Getenv("MACKEREL_AGENT_PLUGIN_META") == "1", "print the graph definitions")
+	logFile, ok := parseArgs(fs, &pf, args)
+	if !ok {
+		return 2
+	}
+	percentiles, err := parsePercentiles(*percentilesFlag)
+	if err != nil || *prefix == "" {
+		fmt.Fprintf(stderr, "followparser metrics: invalid --percentiles or --prefix\n")
+		return 2
+	}
+	if *meta {
+		if err := printMeta(stdout, *prefix, percentiles); err != nil {
+			fmt.Fprintf(stderr, "followparser metrics: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	m := &accessMetrics{statusField: *statusField, latencyField: *latencyField, latencyScale: *latencyScale}
+	if def, ok := defaultLatencyFields[*format]; ok && m.latencyField == "" {
+		m.latencyField = def.name
+		if m.latencyScale == 0 {
+			m.latencyScale = def.scale
+		}
+	}
+	if m.latencyScale == 0 {
+		m.latencyScale = 1
+	}
+	cb, err := recordCallback(*format, *logFormat, []string{m.statusField, m.latencyField}, m.add, m.finish)
+	if err != nil {
+		fmt.Fprintf(stderr, "followparser metrics: %v\n", err)
+		return 2
+	}
+	parser := pf.parser()
+	parser.Callback = cb
+	// with --prom-file, the posfile is committed only after the counters are saved
+	parser.NoAutoCommitPosFile = *promFile != ""
+	parsed, err := parser.Parse(pf.pos, logFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "followparser metrics: %v\n", err)
+		return 1
+	}
+	if *promFile != "" {
+		p, err := parser.NewPromTextfile(pf.pos, *promFile)
+		if err == nil {
+			m.prom(p, *prefix)
+			p.AddParsed(parsed)
+			err = p.Write()
+		}
+		if err == nil {
+			err = parser.CommitPosFile()
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "followparser metrics: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	if err := m.print(stdout, *prefix, percentiles); err != nil {
+		fmt.Fprintf(stderr, "followparser metrics: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunMetrics(t *testing.T) {
+	now = func() time.Time { return time.Unix(1760000000, 0) }
+	defer func() { now = time.Now }()
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "access.log")
+	lines := []string{}
+	for i, status := range []string{"200", "200", "301", "404", "500", "200", "200", "200", "503", "204"} {
+		lines = append(lines, `127.0.0.1 - - [18/Oct/2026:10:00:00 +0000] "GET / HTTP/1.1" `+status+` 12 "-" "curl" `+[]string{"0.100", "0.200", "0.300", "0.400", "0.500", "0.600", "0.700", "0.800", "0.900", "-"}[i])
+	}
+	writeFile(t, logFileName, strings.Join(lines, "\n")+"\n")
+
+	code, stdout, stderr := runCmd(t, "metrics", "--pos", "metricsPos", "--workdir", tmpdir, "--percentiles", "50,90",
+		"--log-format", `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent" $request_time`,
+		logFileName)
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	expected := `accesslog.access_num.total_count	10	1760000000
+accesslog.access_num.1xx_count	0	1760000000
+accesslog.access_num.2xx_count	6	1760000000
+accesslog.access_num.3xx_count	1	1760000000
+accesslog.access_num.4xx_count	1	1760000000
+accesslog.access_num.5xx_count	2	1760000000
+accesslog.latency.average	0.500000	1760000000
+accesslog.latency.50_percentile	0.500000	1760000000
+accesslog.latency.90_percentile	0.900000	1760000000
+`
+	if stdout != expected {
+		t.Fatalf("read '%s' not match expect '%s'", stdout, expected)
+	}
+}
+
+func TestRunMetricsLTSV(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "access.log")
+	writeFile(t, logFileName, "status:200\treqtime:0.5\nstatus:502\treqtime:1.5\n")
+
+	code, stdout, stderr := runCmd(t, "metrics", "--pos", "metricsPos", "--workdir", tmpdir, "--format", "ltsv", "--prefix", "web", logFileName)
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	for _, want := range []string{"web.access_num.total_count\t2\t", "web.access_num.5xx_count\t1\t", "web.latency.average\t1.000000\t", "web.latency.99_percentile\t1.500000\t"} {
+		if !strings.Contains(stdout, want) {
+			t.Fatalf("'%s' does not contain '%s'", stdout, want)
+		}
+	}
+
+	if code, _, _ := runCmd(t, "metrics", "--pos", "metricsPos", "--workdir", tmpdir, "--format", "csv", logFileName); code != 2 {
+		t.Fatalf("exit %d not match expect 2", code)
+	}
+	if code, _, _ := runCmd(t, "metrics", "--pos", "metricsPos", "--workdir", tmpdir, "--percentiles", "101", logFileName); code != 2 {
+		t.Fatalf("exit %d not match expect 2", code)
+	}
+}
+
+func TestRunMetricsMeta(t *testing.T) {
+	t.Setenv("MACKEREL_AGENT_PLUGIN_META", "1")
+	code, stdout, stderr := runCmd(t, "metrics", "--pos", "metricsPos", "--percentiles", "90,99.9", "/nonexistent")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	header, body, ok := strings.Cut(stdout, "\n")
+	if !ok || header != "# mackerel-agent-plugin" {
+		t.Fatalf("unexpected meta '%s'", stdout)
+	}
+	var meta struct {
+		Graphs map[string]graph `json:"graphs"`
+	}
+	if err := json.Unmarshal([]byte(body), &meta); err != nil {
+		t.Fatal(err)
+	}
+	latency := meta.Graphs["accesslog.latency"]
+	if len(meta.Graphs) != 3 || len(latency.Metrics) != 3 || latency.Metrics[2].Name != "99_9_percentile" {
+		t.Fatalf("unexpected graphs %+v", meta.Graphs)
+	}
+	if num := meta.Graphs["accesslog.access_num"]; len(num.Metrics) != 6 || !num.Metrics[1].Stacked {
+		t.Fatalf("unexpected graph %+v", num)
+	}
+}
+
+func TestRunMetricsPromFile(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "access.log")
+	promFile := filepath.Join(tmpdir, "access.prom")
+	writeFile(t, logFileName, "status:200\treqtime:0.05\nstatus:502\treqtime:1.5\n")
+
+	args := []string{"metrics", "--pos", "metricsPos", "--workdir", tmpdir, "--format", "ltsv", "--prom-file", promFile, logFileName}
+	if code, stdout, stderr := runCmd(t, args...); code != 0 || stdout != "" {
+		t.Fatalf("exit %d: %s %s", code, stdout, stderr)
+	}
+	appendLog(t, logFileName, "status:200\treqtime:0.2\n")
+	if code, _, stderr := runCmd(t, args...); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	b, err := os.ReadFile(promFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE accesslog_requests_total counter\n",
+		`accesslog_requests_total{status_class="2xx"} 2` + "\n",
+		`accesslog_requests_total{status_class="5xx"} 1` + "\n",
+		`accesslog_request_duration_seconds_bucket{le="0.05"} 1` + "\n",
+		`accesslog_request_duration_seconds_bucket{le="+Inf"} 3` + "\n",
+		"accesslog_request_duration_seconds_count 3\n",
+		"followparser_rows_total 3\n",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Fatalf("'%s' does not contain '%s'", b, want)
+		}
+	}
+
+	// the posfile is not committed if the textfile cannot be written
+	appendLog(t, logFileName, "status:404\treqtime:0.1\n")
+	args[len(args)-2] = filepath.Join(tmpdir, "nosuch", "access.prom")
+	if code, _, _ := runCmd(t, args...); code != 1 {
+		t.Fatalf("exit %d not match expect 1", code)
+	}
+	args[len(args)-2] = promFile
+	if code, _, stderr := runCmd(t, args...); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	if b, _ := os.ReadFile(promFile); !strings.Contains(string(b), `accesslog_requests_total{status_class="4xx"} 1`+"\n") {
+		t.Fatalf("the lines of the failed run must be counted '%s'", b)
+	}
+}
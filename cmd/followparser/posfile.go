@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+func runShow(args []string, stdout, stderr io.Writer) int {
+	var pf parserFlags
+	fs := newFlagSet("show", stderr, &pf)
+	logFile, ok := parseArgs(fs, &pf, args)
+	if !ok {
+		return 2
+	}
+	p, err := pf.parser().Position(pf.pos, logFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "followparser show: %v\n", err)
+		return 1
+	}
+	if !p.Exists {
+		fmt.Fprintf(stdout, "posfile\t%s\ncommitted\tfalse\n", p.Path)
+		return 0
+	}
+	fmt.Fprintf(stdout, "posfile\t%s\ncommitted\ttrue\noffset\t%d\ninode\t%d\ndev\t%d\ncommitted_at\t%s\nage\t%.0f\nfile\t%s\n",
+		p.Path, p.Offset, p.Inode, p.Dev, p.CommittedAt.Format(time.RFC3339), time.Since(p.CommittedAt).Seconds(), p.FileName)
+	return 0
+}
+
+func runReset(args []string, stdout, stderr io.Writer) int {
+	var pf parserFlags
+	fs := newFlagSet("reset", stderr, &pf)
+	toEnd := fs.Bool("end", false, "reset to the end of the file instead of the start")
+	logFile, ok := parseArgs(fs, &pf, args)
+	if !ok {
+		return 2
+	}
+	offset := int64(0)
+	if *toEnd {
+		st, err := os.Stat(logFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "followparser reset: %v\n", err)
+			return 1
+		}
+		offset = st.Size()
+	}
+	return setPosition("reset", &pf, logFile, offset, stdout, stderr)
+}
+
+func runSet(args []string, stdout, stderr io.Writer) int {
+	var pf parserFlags
+	fs := newFlagSet("set", stderr, &pf)
+	offset := fs.Int64("offset", -1, "byte offset to set (required)")
+	logFile, ok := parseArgs(fs, &pf, args)
+	if !ok {
+		return 2
+	}
+	if *offset < 0 {
+		fs.Usage()
+		return 2
+	}
+	return setPosition("set", &pf, logFile, *offset, stdout, stderr)
+}
+
+func setPosition(name string, pf *parserFlags, logFile string, offset int64, stdout, stderr io.Writer) int {
+	if err := pf.parser().SetPosition(pf.pos, logFile, offset); err != nil {
+		fmt.Fprintf(stderr, "followparser %s: %v\n", name, err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "offset\t%d\n", offset)
+	return 0
+}
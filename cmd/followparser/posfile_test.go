@@ -0,0 +1,53 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunPosfileCommands(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	writeFile(t, logFileName, "line1\nline2\n")
+	flags := []string{"--pos", "posfilePos", "--workdir", tmpdir}
+	cmd := func(name string, args ...string) []string {
+		return append(append([]string{name}, flags...), append(args, logFileName)...)
+	}
+
+	if code, stdout, _ := runCmd(t, cmd("show")...); code != 0 || !strings.Contains(stdout, "committed\tfalse\n") {
+		t.Fatalf("exit %d unexpected show '%s'", code, stdout)
+	}
+
+	if code, stdout, stderr := runCmd(t, cmd("reset", "--end")...); code != 0 || stdout != "offset\t12\n" {
+		t.Fatalf("exit %d unexpected reset '%s': %s", code, stdout, stderr)
+	}
+	code, stdout, _ := runCmd(t, cmd("show")...)
+	if code != 0 || !strings.Contains(stdout, "offset\t12\n") || !strings.Contains(stdout, "file\t"+logFileName+"\n") {
+		t.Fatalf("exit %d unexpected show '%s'", code, stdout)
+	}
+	if code, stdout, _ := runCmd(t, cmd("read")...); code != 0 || stdout != "" {
+		t.Fatalf("exit %d read '%s' not match expect ''", code, stdout)
+	}
+
+	if code, _, stderr := runCmd(t, cmd("set", "--offset", "6")...); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	if code, stdout, _ := runCmd(t, cmd("read")...); code != 0 || stdout != "line2\n" {
+		t.Fatalf("exit %d read '%s' not match expect '%s'", code, stdout, "line2\n")
+	}
+
+	if code, _, _ := runCmd(t, cmd("set", "--offset", "100")...); code != 1 {
+		t.Fatalf("exit %d not match expect 1", code)
+	}
+	if code, _, _ := runCmd(t, cmd("set")...); code != 2 {
+		t.Fatalf("exit %d not match expect 2", code)
+	}
+
+	if code, _, _ := runCmd(t, cmd("reset")...); code != 0 {
+		t.Fatalf("exit %d not match expect 0", code)
+	}
+	if code, stdout, _ := runCmd(t, cmd("read")...); code != 0 || stdout != "line1\nline2\n" {
+		t.Fatalf("exit %d read '%s' not match expect '%s'", code, stdout, "line1\nline2\n")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/monitoring-forge/followparser"
+)
+
+// writeCallback writes lines to w and stops at the first write error
+type writeCallback struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (c *writeCallback) Parse(b []byte) error {
+	if _, err := c.w.Write(b); err != nil {
+		c.err = err
+		return followparser.ErrStop
+	}
+	if err := c.w.WriteByte('\n'); err != nil {
+		c.err = err
+		return followparser.ErrStop
+	}
+	return nil
+}
+
+func (c *writeCallback) Finish(_ float64) {
+}
+
+func runRead(args []string, stdout, stderr io.Writer) int {
+	var pf parserFlags
+	fs := newFlagSet("read", stderr, &pf)
+	maxReadSize := fs.Int64("max-read-size", 0, fmt.Sprintf("skip to the end if more than this many bytes are pending (default: %d)", followparser.DefaultMaxReadSize))
+	noCommit := fs.Bool("no-commit", false, "do not update the posfile")
+	silent := fs.Bool("silent", false, "do not log to stderr")
+	logFile, ok := parseArgs(fs, &pf, args)
+	if !ok {
+		return 2
+	}
+	cb := &writeCallback{w: bufio.NewWriter(stdout)}
+	parser := pf.parser()
+	parser.Callback = cb
+	parser.MaxReadSize = *maxReadSize
+	parser.Silent = *silent
+	// the posfile is committed only after the lines are written out
+	parser.NoAutoCommitPosFile = true
+	if _, err := parser.Parse(pf.pos, logFile); err != nil {
+		fmt.Fprintf(stderr, "followparser read: %v\n", err)
+		return 1
+	}
+	if cb.err == nil {
+		cb.err = cb.w.Flush()
+	}
+	if cb.err != nil {
+		fmt.Fprintf(stderr, "followparser read: failed to write lines :%v\n", cb.err)
+		return 1
+	}
+	if *noCommit {
+		return 0
+	}
+	if err := parser.CommitPosFile(); err != nil {
+		fmt.Fprintf(stderr, "followparser read: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func appendLog(t *testing.T, name, s string) {
+	t.Helper()
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunRead(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	appendLog(t, logFileName, "line1\nline2\n")
+	args := []string{"read", "--pos", "readPos", "--workdir", tmpdir, "--silent", logFileName}
+
+	code, stdout, stderr := runCmd(t, args...)
+	if code != 0 || stdout != "line1\nline2\n" {
+		t.Fatalf("exit %d read '%s' not match expect '%s': %s", code, stdout, "line1\nline2\n", stderr)
+	}
+
+	// a failed write does not commit
+	appendLog(t, logFileName, "line3\n")
+	if code := run(args, failingWriter{}, io.Discard); code != 1 {
+		t.Fatalf("exit %d not match expect 1", code)
+	}
+	// --no-commit prints the lines without committing
+	noCommit := append([]string{"read", "--no-commit"}, args[1:]...)
+	if code, stdout, _ := runCmd(t, noCommit...); code != 0 || stdout != "line3\n" {
+		t.Fatalf("exit %d read '%s' not match expect '%s'", code, stdout, "line3\n")
+	}
+
+	// rotate
+	appendLog(t, logFileName, "line4")
+	if err := os.Rename(logFileName, logFileName+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendLog(t, logFileName, "line5\n")
+	if code, stdout, _ := runCmd(t, args...); code != 0 || stdout != "line3\nline4\nline5\n" {
+		t.Fatalf("exit %d read '%s' not match expect '%s'", code, stdout, "line3\nline4\nline5\n")
+	}
+	if code, stdout, _ := runCmd(t, args...); code != 0 || stdout != "" {
+		t.Fatalf("exit %d read '%s' not match expect ''", code, stdout)
+	}
+}
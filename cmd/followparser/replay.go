@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/monitoring-forge/followparser"
+)
+
+func runReplay(args []string, stdout, stderr io.Writer) int {
+	var tf timeFlags
+	fs := flag.NewFlagSet("followparser replay", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: followparser replay [flags] <logfile>...")
+		fs.PrintDefaults()
+	}
+	start := fs.Int64("start", 0, "byte offset to start at in each file")
+	end := fs.Int64("end", 0, "byte offset to end at in each file, a line crossing it is not printed (default: the end of the file)")
+	since := fs.String("since", "", "print the lines at or after this time, RFC3339 or --time-layout")
+	until := fs.String("until", "", "print the lines at or before this time, RFC3339 or --time-layout")
+	silent := fs.Bool("silent", false, "do not print the summary of each file to stderr")
+	tf.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	r := followparser.ReplayRange{StartPos: *start, EndPos: *end}
+	var err error
+	if *since != "" {
+		if r.Since, err = tf.parseTime(*since); err != nil {
+			fmt.Fprintf(stderr, "followparser replay: %v\n", err)
+			return 2
+		}
+	}
+	if *until != "" {
+		if r.Until, err = tf.parseTime(*until); err != nil {
+			fmt.Fprintf(stderr, "followparser replay: %v\n", err)
+			return 2
+		}
+	}
+	if *since != "" || *until != "" {
+		if r.TimeOf, err = tf.timeFunc(); err != nil {
+			fmt.Fprintf(stderr, "followparser replay: %v\n", err)
+			return 2
+		}
+	}
+
+	cb := &writeCallback{w: bufio.NewWriter(stdout)}
+	parser := &followparser.Parser{Callback: cb, Silent: true}
+	parsed, err := parser.Replay(fs.Args(), r)
+	if cb.err == nil {
+		cb.err = cb.w.Flush()
+	}
+	if !*silent {
+		for _, p := range parsed {
+			fmt.Fprintf(stderr, "%s\tstart:%d\tend:%d\trows:%d\n", p.FileName, p.StartPos, p.EndPos, p.Rows)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "followparser replay: %v\n", err)
+		return 1
+	}
+	if cb.err != nil {
+		fmt.Fprintf(stderr, "followparser replay: failed to write lines :%v\n", cb.err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunReplay(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	writeFile(t, logFileName, "[18/Oct/2026:10:00:00 +0000] a\n[18/Oct/2026:10:00:10 +0000] b\ncontinued\n[18/Oct/2026:10:00:20 +0000] c\n")
+
+	code, stdout, stderr := runCmd(t, "replay", "--since", "2026-10-18T10:00:05Z", "--until", "2026-10-18T10:00:15Z", logFileName)
+	if code != 0 || stdout != "[18/Oct/2026:10:00:10 +0000] b\ncontinued\n" {
+		t.Fatalf("exit %d unexpected replay '%s': %s", code, stdout, stderr)
+	}
+	if !strings.Contains(stderr, logFileName+"\tstart:31\t") || !strings.Contains(stderr, "rows:2\n") {
+		t.Fatalf("unexpected summary '%s'", stderr)
+	}
+
+	code, stdout, stderr = runCmd(t, "replay", "--start", "31", "--end", "62", "--silent", logFileName)
+	if code != 0 || stdout != "[18/Oct/2026:10:00:10 +0000] b\n" || stderr != "" {
+		t.Fatalf("exit %d unexpected replay '%s': %s", code, stdout, stderr)
+	}
+
+	for _, args := range [][]string{
+		{},
+		{"--since", "yesterday", logFileName},
+		{"--since", "2026-10-18T10:00:05Z", "--time-regexp", "no submatch", logFileName},
+	} {
+		if code, _, _ := runCmd(t, append([]string{"replay"}, args...)...); code != 2 {
+			t.Fatalf("%v exit %d not match expect 2", args, code)
+		}
+	}
+	if code, _, _ := runCmd(t, "replay", filepath.Join(tmpdir, "nosuch")); code != 1 {
+		t.Fatalf("exit %d not match expect 1", code)
+	}
+}
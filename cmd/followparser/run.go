@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/monitoring-forge/followparser"
+)
+
+// filterCallback writes the lines and records that pass the filters of a log
+type filterCallback struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+	fields  []fieldFilter
+	// outFields are written separated by tabs instead of the line if set
+	outFields []string
+	// decoder passes the records to record, nil for raw lines
+	decoder followparser.Callback
+	w       *bufio.Writer
+	line    []byte
+	lines   int
+	err     error
+}
+
+func (c *filterCallback) Parse(b []byte) error {
+	if len(c.include) > 0 && !matchAny(c.include, b) {
+		return nil
+	}
+	if matchAny(c.exclude, b) {
+		return nil
+	}
+	c.line = b
+	if c.decoder == nil {
+		return c.write(nil)
+	}
+	return c.decoder.Parse(b)
+}
+
+func (c *filterCallback) record(r followparser.Record) error {
+	for _, f := range c.fields {
+		if v, ok := r.Get(f.name); !ok || !f.re.Match(v) {
+			return nil
+		}
+	}
+	return c.write(r)
+}
+
+func (c *filterCallback) write(r followparser.Record) error {
+	if len(c.outFields) == 0 {
+		c.w.Write(c.line)
+	} else {
+		for i, name := range c.outFields {
+			if i > 0 {
+				c.w.WriteByte('\t')
+			}
+			v, _ := r.Get(name)
+			c.w.Write(v)
+		}
+	}
+	// bufio.Writer keeps the first error
+	if err := c.w.WriteByte('\n'); err != nil {
+		c.err = err
+		return followparser.ErrStop
+	}
+	c.lines++
+	return nil
+}
+
+func (c *filterCallback) Finish(_ float64) {
+}
+
+// output is where the lines of a log are written
+type output interface {
+	io.Writer
+	// Close finishes the output, the posfile is committed only if it succeeds
+	Close() error
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// execOutput starts the command on the first write and passes the lines on stdin
+type execOutput struct {
+	command []string
+	stdout  io.Writer
+	stderr  io.Writer
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+}
+
+func (o *execOutput) Write(b []byte) (int, error) {
+	if o.cmd == nil {
+		o.cmd = exec.Command(o.command[0], o.command[1:]...)
+		o.cmd.Stdout = o.stdout
+		o.cmd.Stderr = o.stderr
+		stdin, err := o.cmd.StdinPipe()
+		if err != nil {
+			return 0, err
+		}
+		o.stdin = stdin
+		if err := o.cmd.Start(); err != nil {
+			return 0, fmt.Errorf("failed to start %s :%v", o.command[0], err)
+		}
+	}
+	return o.stdin.Write(b)
+}
+
+func (o *execOutput) Close() error {
+	if o.cmd == nil {
+		return nil
+	}
+	o.stdin.Close()
+	if err := o.cmd.Wait(); err != nil {
+		return fmt.Errorf("%s failed :%v", o.command[0], err)
+	}
+	return nil
+}
+
+func (l *logConfig) output(stdout, stderr io.Writer) (output, error) {
+	switch l.Output.Type {
+	case "file":
+		f, err := os.OpenFile(l.Output.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open output :%v", err)
+		}
+		return f, nil
+	case "exec":
+		return &execOutput{command: l.Output.Command, stdout: stdout, stderr: stderr}, nil
+	}
+	return nopCloser{stdout}, nil
+}
+
+// files returns the log files and their posfile names
+func (l *logConfig) files() ([]string, []string, error) {
+	if !strings.ContainsAny(l.Path, "*?[") {
+		return []string{l.Path}, []string{l.Pos}, nil
+	}
+	files, err := filepath.Glob(l.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	poses := make([]string, 0, len(files))
+	seen := map[string]string{}
+	for _, file := range files {
+		base := filepath.Base(file)
+		if other, ok := seen[base]; ok {
+			return nil, nil, fmt.Errorf("%s and %s have the same base name", other, file)
+		}
+		seen[base] = file
+		poses = append(poses, l.Pos+"-"+base)
+	}
+	return files, poses, nil
+}
+
+// runner runs the logs of a config with shared limits
+type runner struct {
+	cfg      *runConfig
+	limiter  *followparser.RateLimiter
+	deadline time.Time
+	stdout   io.Writer
+	stderr   io.Writer
+}
+
+// runFile reads a log file, writes the lines to the output and commits the posfile
+func (r *runner) runFile(l *logConfig, pos, logFile string) error {
+	cb := &filterCallback{
+		include:   l.Filters.include,
+		exclude:   l.Filters.exclude,
+		fields:    l.Filters.fields,
+		outFields: l.Output.Fields,
+	}
+	if l.Decoder.Type != "raw" {
+		format := l.Decoder.Format
+		if l.Decoder.Type == "regex" {
+			format = l.Decoder.Pattern
+		}
+		decoder, err := recordCallback(l.Decoder.Type, format, l.lookupFields(), cb.record, nil)
+		if err != nil {
+			return err
+		}
+		cb.decoder = decoder
+	}
+	out, err := l.output(r.stdout, r.stderr)
+	if err != nil {
+		return err
+	}
+	cb.w = bufio.NewWriter(out)
+	parser := &followparser.Parser{
+		WorkDir:     r.cfg.WorkDir,
+		ArchiveDir:  l.ArchiveDir,
+		MaxReadSize: l.MaxReadSize,
+		Callback:    cb,
+		Silent:      true,
+		RateLimiter: r.limiter,
+		// the posfile is committed only after the lines are written out
+		NoAutoCommitPosFile: true,
+	}
+	if !r.deadline.IsZero() {
+		parser.MaxDuration = time.Until(r.deadline)
+	}
+	parsed, err := parser.Parse(pos, logFile)
+	if err == nil {
+		err = cb.err
+	}
+	if err == nil {
+		err = cb.w.Flush()
+	}
+	if cerr := out.Close(); err == nil && cerr != nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := parser.CommitPosFile(); err != nil {
+		return err
+	}
+	for _, p := range parsed {
+		fmt.Fprintf(r.stderr, "%s\t%s\tstart:%d\tend:%d\trows:%d\tlines:%d\terrors:%d\n", l.Name, p.FileName, p.StartPos, p.EndPos, p.Rows, cb.lines, p.Errors)
+	}
+	return nil
+}
+
+func runRun(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("followparser run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: followparser run -c <config>\n")
+		fs.PrintDefaults()
+	}
+	config := fs.String("c", "", "config file (required)")
+	validate := fs.Bool("validate", false, "only validate the config")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *config == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	cfg, err := loadConfig(*config)
+	if err != nil {
+		fmt.Fprintf(stderr, "followparser run: %v\n", err)
+		return 2
+	}
+	if *validate {
+		return 0
+	}
+
+	r := &runner{cfg: cfg, stdout: stdout, stderr: stderr}
+	if cfg.RateLimit.BytesPerSec > 0 || cfg.RateLimit.LinesPerSec > 0 {
+		r.limiter = followparser.NewRateLimiter(cfg.RateLimit.BytesPerSec, cfg.RateLimit.LinesPerSec)
+	}
+	if cfg.MaxDuration > 0 {
+		r.deadline = time.Now().Add(cfg.MaxDuration)
+	}
+	if cfg.BufferLimit > 0 {
+		followparser.SetBufferPoolLimit(cfg.BufferLimit)
+		defer followparser.SetBufferPoolLimit(0)
+	}
+	code := 0
+	for i := range cfg.Logs {
+		l := &cfg.Logs[i]
+		files, poses, err := l.files()
+		if err != nil {
+			fmt.Fprintf(stderr, "followparser run: %s: %v\n", l.Name, err)
+			code = 1
+			continue
+		}
+		for j, file := range files {
+			if !r.deadline.IsZero() && !time.Now().Before(r.deadline) {
+				fmt.Fprintf(stderr, "followparser run: %s: %s is left for the next run\n", l.Name, file)
+				continue
+			}
+			if err := r.runFile(l, poses[j], file); err != nil {
+				fmt.Fprintf(stderr, "followparser run: %s: %v\n", l.Name, err)
+				code = 1
+			}
+		}
+	}
+	return code
+}
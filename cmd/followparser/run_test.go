@@ -0,0 +1,181 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunValidate(t *testing.T) {
+	tmpdir := t.TempDir()
+	config := filepath.Join(tmpdir, "config.yaml")
+	writeFile(t, config, `
+logs:
+  - path: /var/log/app.log
+    decoder:
+      type: regex
+      pattern: '^(\S+) (.*)$'
+    filters:
+      include: ['(']
+      fields:
+        level: ERROR
+    output:
+      type: exec
+  - path: /var/log/access.log
+    pos: access
+    decoder:
+      type: nginx
+    output:
+      fields: [status, nosuch]
+  - path: /var/log/access.log
+    pos: access
+    decoder:
+      type: csv
+    output:
+      type: file
+`)
+	code, _, stderr := runCmd(t, "run", "-c", config, "--validate")
+	if code != 2 {
+		t.Fatalf("exit %d not match expect 2", code)
+	}
+	for _, want := range []string{
+		"logs[0] /var/log/app.log: pos is required",
+		"logs[0] /var/log/app.log: pattern has no named groups",
+		"logs[0] /var/log/app.log: invalid include",
+		`logs[0] /var/log/app.log: decoder regex has no field "level"`,
+		"logs[0] /var/log/app.log: command of the exec output is required",
+		`logs[1] access: decoder nginx has no field "nosuch"`,
+		`logs[2] access: unknown decoder "csv"`,
+		"logs[2] access: path of the file output is required",
+		`logs[2] access: pos "access" is used twice`,
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("'%s' does not contain '%s'", stderr, want)
+		}
+	}
+
+	writeFile(t, config, "logs:\n  - path: /var/log/app.log\n    pos: app\n    unknown: 1\n")
+	if code, _, stderr := runCmd(t, "run", "-c", config); code != 2 || !strings.Contains(stderr, "field unknown not found") {
+		t.Fatalf("unexpected exit %d stderr '%s'", code, stderr)
+	}
+	writeFile(t, config, "logs:\n  - path: /var/log/app.log\n    pos: app\n")
+	if code, _, stderr := runCmd(t, "run", "-c", config, "--validate"); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	if code, _, _ := runCmd(t, "run"); code != 2 {
+		t.Fatalf("exit %d not match expect 2", code)
+	}
+}
+
+func TestRunConfig(t *testing.T) {
+	tmpdir := t.TempDir()
+	appDir := filepath.Join(tmpdir, "app")
+	if err := os.Mkdir(appDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(appDir, "a.log"), "a1 INFO ok\na2 ERROR ng\n")
+	writeFile(t, filepath.Join(appDir, "b.log"), "b1 ERROR ng\nb2 ERROR healthcheck\n")
+	accessLog := filepath.Join(tmpdir, "access.log")
+	writeFile(t, accessLog, "status:200\tpath:/\nstatus:502\tpath:/api\nnot ltsv\n")
+	outFile := filepath.Join(tmpdir, "errors.tsv")
+	config := filepath.Join(tmpdir, "config.yaml")
+	writeFile(t, config, `
+workdir: `+tmpdir+`
+max_duration: 1m
+rate_limit:
+  lines_per_sec: 100000
+logs:
+  - name: app
+    path: `+filepath.Join(appDir, "*.log")+`
+    pos: app
+    filters:
+      include: [ERROR]
+      exclude: [healthcheck]
+  - path: `+accessLog+`
+    pos: access
+    decoder:
+      type: ltsv
+    filters:
+      fields:
+        status: '^5'
+    output:
+      type: file
+      path: `+outFile+`
+      fields: [status, path]
+`)
+	code, stdout, stderr := runCmd(t, "run", "-c", config)
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	if expected := "a2 ERROR ng\nb1 ERROR ng\n"; stdout != expected {
+		t.Fatalf("read '%s' not match expect '%s'", stdout, expected)
+	}
+	if b, _ := os.ReadFile(outFile); string(b) != "502\t/api\n" {
+		t.Fatalf("read '%s' not match expect '%s'", b, "502\t/api\n")
+	}
+	if !strings.Contains(stderr, "access\t"+accessLog+"\tstart:0\tend:48\trows:3\tlines:1\terrors:1\n") {
+		t.Fatalf("unexpected summary '%s'", stderr)
+	}
+
+	// only the new lines are read in the next run
+	appendLog(t, filepath.Join(appDir, "a.log"), "a3 ERROR again\n")
+	writeFile(t, filepath.Join(appDir, "c.log"), "c1 ERROR new file\n")
+	if code, stdout, stderr := runCmd(t, "run", "-c", config); code != 0 || stdout != "a3 ERROR again\nc1 ERROR new file\n" {
+		t.Fatalf("unexpected exit %d stdout '%s' stderr '%s'", code, stdout, stderr)
+	}
+}
+
+func TestRunExec(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not installed")
+	}
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "app.log")
+	outFile := filepath.Join(tmpdir, "out")
+	writeFile(t, logFileName, "2026-10-18 ERROR disk full\n2026-10-18 INFO ok\n")
+	config := filepath.Join(tmpdir, "config.yaml")
+	writeConfig := func(command string) {
+		writeFile(t, config, `
+workdir: `+tmpdir+`
+logs:
+  - path: `+logFileName+`
+    pos: app
+    decoder:
+      type: regex
+      pattern: '^(?P<date>\S+) (?P<level>[A-Z]+) (?P<message>.*)$'
+    filters:
+      fields:
+        level: ERROR
+    output:
+      type: exec
+      command: [`+sh+`, -c, '`+command+`']
+      fields: [message]
+`)
+	}
+
+	// the posfile is not committed if the command fails
+	writeConfig("cat >/dev/null; exit 1")
+	if code, _, stderr := runCmd(t, "run", "-c", config); code != 1 || !strings.Contains(stderr, "failed") {
+		t.Fatalf("unexpected exit %d stderr '%s'", code, stderr)
+	}
+	writeConfig("cat >> " + outFile)
+	if code, _, stderr := runCmd(t, "run", "-c", config); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	if b, _ := os.ReadFile(outFile); string(b) != "disk full\n" {
+		t.Fatalf("read '%s' not match expect '%s'", b, "disk full\n")
+	}
+	// the command is not run without lines
+	if err := os.Remove(outFile); err != nil {
+		t.Fatal(err)
+	}
+	if code, _, stderr := runCmd(t, "run", "-c", config); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	if _, err := os.Stat(outFile); err == nil {
+		t.Fatal("the command should not be run")
+	}
+}
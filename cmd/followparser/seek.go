@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/monitoring-forge/followparser"
+)
+
+// timeFlags are the flags to find the time of a line
+type timeFlags struct {
+	regexp string
+	layout string
+}
+
+func (tf *timeFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&tf.regexp, "time-regexp", `\[([^\]]+)\]`, "regexp whose first submatch is the time of a line")
+	fs.StringVar(&tf.layout, "time-layout", "02/Jan/2006:15:04:05 -0700", "Go time layout of the time in a line")
+}
+
+// parseTime parses a time flag in RFC3339 or the layout of the log
+func (tf *timeFlags) parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, err = time.Parse(tf.layout, s)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", s)
+	}
+	return t, nil
+}
+
+func (tf *timeFlags) timeFunc() (followparser.TimeFunc, error) {
+	re, err := regexp.Compile(tf.regexp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --time-regexp :%v", err)
+	}
+	if re.NumSubexp() < 1 {
+		return nil, errors.New("--time-regexp must have a submatch")
+	}
+	return followparser.RegexpTimeFunc(re, tf.layout), nil
+}
+
+func runSeekTime(args []string, stdout, stderr io.Writer) int {
+	var pf parserFlags
+	var tf timeFlags
+	fs := newFlagSet("seek-time", stderr, &pf)
+	at := fs.String("time", "", "seek to the first line at or after this time, RFC3339 or --time-layout (required)")
+	tf.register(fs)
+	logFile, ok := parseArgs(fs, &pf, args)
+	if !ok {
+		return 2
+	}
+	if *at == "" {
+		fs.Usage()
+		return 2
+	}
+	t, err := tf.parseTime(*at)
+	if err != nil {
+		fmt.Fprintf(stderr, "followparser seek-time: %v\n", err)
+		return 2
+	}
+	timeOf, err := tf.timeFunc()
+	if err != nil {
+		fmt.Fprintf(stderr, "followparser seek-time: %v\n", err)
+		return 2
+	}
+	p, err := pf.parser().SeekTime(pf.pos, logFile, t, timeOf)
+	if err != nil {
+		fmt.Fprintf(stderr, "followparser seek-time: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "file\t%s\noffset\t%d\n", p.FileName, p.Offset)
+	return 0
+}
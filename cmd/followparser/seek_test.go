@@ -0,0 +1,39 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRunSeekTime(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	writeFile(t, logFileName, "[18/Oct/2026:10:00:00 +0000] a\n[18/Oct/2026:10:00:10 +0000] b\n[18/Oct/2026:10:00:20 +0000] c\n")
+	flags := []string{"seek-time", "--pos", "seekPos", "--workdir", tmpdir}
+
+	code, stdout, stderr := runCmd(t, append(flags, "--time", "2026-10-18T10:00:05Z", logFileName)...)
+	if code != 0 || stdout != "file\t"+logFileName+"\noffset\t31\n" {
+		t.Fatalf("exit %d unexpected seek-time '%s': %s", code, stdout, stderr)
+	}
+	code, stdout, _ = runCmd(t, "read", "--pos", "seekPos", "--workdir", tmpdir, "--silent", logFileName)
+	if code != 0 || stdout != "[18/Oct/2026:10:00:10 +0000] b\n[18/Oct/2026:10:00:20 +0000] c\n" {
+		t.Fatalf("exit %d unexpected read '%s'", code, stdout)
+	}
+
+	// the time can be given in the layout of the log
+	code, stdout, _ = runCmd(t, append(flags, "--time", "18/Oct/2026:19:00:20 +0900", logFileName)...)
+	if code != 0 || stdout != "file\t"+logFileName+"\noffset\t62\n" {
+		t.Fatalf("exit %d unexpected seek-time '%s'", code, stdout)
+	}
+
+	for _, args := range [][]string{
+		{"--time", "yesterday"},
+		{"--time", "2026-10-18T10:00:05Z", "--time-regexp", "("},
+		{"--time", "2026-10-18T10:00:05Z", "--time-regexp", "no submatch"},
+		{},
+	} {
+		if code, _, _ := runCmd(t, append(append(flags, args...), logFileName)...); code != 2 {
+			t.Fatalf("%v exit %d not match expect 2", args, code)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package followparser
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a serialized Cursor cannot be decoded
+var ErrInvalidCursor = errors.New("cursor: invalid cursor")
+
+// Cursor is an opaque position in a log file. It identifies the file by inode,
+// so a Cursor taken in a file that has been rotated since is still resumed in the rotated file.
+// A Cursor is serialized with MarshalText and can be stored outside of the posfile.
+type Cursor struct {
+	pos fPos
+}
+
+func newCursor(pos int64, fstat *fStat) *Cursor {
+	return &Cursor{pos: fPos{
+		Pos:   pos,
+		Time:  float64(time.Now().Unix()),
+		Inode: fstat.Inode,
+		Dev:   fstat.Dev,
+	}}
+}
+
+// Offset returns the byte offset in the file
+func (c *Cursor) Offset() int64 {
+	return c.pos.Pos
+}
+
+// MarshalText encodes the Cursor as a URL safe token
+func (c *Cursor) MarshalText() ([]byte, error) {
+	jb, err := json.Marshal(c.pos)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, base64.RawURLEncoding.EncodedLen(len(jb)))
+	base64.RawURLEncoding.Encode(b, jb)
+	return b, nil
+}
+
+// UnmarshalText decodes a token encoded by MarshalText
+func (c *Cursor) UnmarshalText(b []byte) error {
+	jb := make([]byte, base64.RawURLEncoding.DecodedLen(len(b)))
+	n, err := base64.RawURLEncoding.Decode(jb, b)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	var pos fPos
+	if err := json.Unmarshal(jb[:n], &pos); err != nil || pos.Pos < 0 {
+		return ErrInvalidCursor
+	}
+	c.pos = pos
+	return nil
+}
+
+func (c *Cursor) String() string {
+	b, _ := c.MarshalText()
+	return string(b)
+}
+
+func (c *Cursor) fStat() *fStat {
+	return &fStat{Inode: c.pos.Inode, Dev: c.pos.Dev}
+}
+
+// Commit writes the position of c to the posfile of the last Parse.
+// Use it with NoAutoCommitPosFile to commit a Parsed.Cursor after the lines have been acknowledged downstream.
+func (parser *Parser) Commit(c *Cursor) error {
+	if parser.posFile == nil {
+		return fmt.Errorf("failed to commit cursor :no pos file, Parse has not been called")
+	}
+	err := parser.posFile.write(c.pos.Pos, c.fStat())
+	if err != nil {
+		return fmt.Errorf("failed to update pos file :%v", err)
+	}
+	return nil
+}
+
+// Resume parses logFile from c like Parse, without reading or writing a posfile.
+// A nil Cursor reads logFile as if there was no posfile.
+// The position reached is returned in the Cursor of the last Parsed.
+func (parser *Parser) Resume(c *Cursor, logFile string) ([]Parsed, error) {
+	parser.setDefaults(logFile)
+	parser.posFile = nil
+	if c == nil {
+		return parser.parse(logFile, 0, 0, nil)
+	}
+	duration := float64(time.Now().Unix()) - c.pos.Time
+	return parser.parse(logFile, c.pos.Pos, duration, c.fStat())
+}
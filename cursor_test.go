@@ -0,0 +1,124 @@
+package followparser
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func appendFile(t *testing.T, name, s string) {
+	t.Helper()
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCursorText(t *testing.T) {
+	c := newCursor(123, &fStat{Inode: 4, Dev: 5})
+	b, err := c.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c2 Cursor
+	if err := c2.UnmarshalText(b); err != nil {
+		t.Fatal(err)
+	}
+	if c2 != *c || c2.Offset() != 123 {
+		t.Fatalf("cursor %v not match expect %v", c2, c)
+	}
+	for _, s := range []string{"", "!!!", "bm90IGpzb24"} {
+		if err := c2.UnmarshalText([]byte(s)); err != ErrInvalidCursor {
+			t.Fatalf("'%s' should be invalid: %v", s, err)
+		}
+	}
+}
+
+func TestParseCommitCursor(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	appendFile(t, logFileName, "line1\nline2\n")
+
+	parser := &testParser{buf: bytes.NewBufferString("")}
+	fp := &Parser{WorkDir: tmpdir, Callback: parser, Silent: true, NoAutoCommitPosFile: true}
+	r, err := fp.Parse("logPosCursor", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor := r[len(r)-1].Cursor
+	parser.buf.Reset()
+	appendFile(t, logFileName, "line3\n")
+
+	// not committed yet
+	if _, err := fp.Parse("logPosCursor", logFileName); err != nil {
+		t.Fatal(err)
+	}
+	if read := parser.Slurp().String(); read != "line1\nline2\nline3\n" {
+		t.Fatalf("read '%s' not match expect '%s'", read, "line1\nline2\nline3\n")
+	}
+	parser.buf.Reset()
+
+	// commit the cursor of the first run out of band
+	if err := fp.Commit(cursor); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fp.Parse("logPosCursor", logFileName); err != nil {
+		t.Fatal(err)
+	}
+	if read := parser.Slurp().String(); read != "line3\n" {
+		t.Fatalf("read '%s' not match expect '%s'", read, "line3\n")
+	}
+}
+
+func TestResumeCursor(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	appendFile(t, logFileName, "line1\nline2\n")
+
+	parser := &testParser{buf: bytes.NewBufferString("")}
+	fp := &Parser{WorkDir: tmpdir, Callback: parser, Silent: true}
+	r, err := fp.Resume(nil, logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read := parser.Slurp().String(); read != "line1\nline2\n" {
+		t.Fatalf("read '%s' not match expect '%s'", read, "line1\nline2\n")
+	}
+	parser.buf.Reset()
+	token, err := r[len(r)-1].Cursor.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rotate, the rest of the rotated file is read from the cursor
+	appendFile(t, logFileName, "line3")
+	if err := os.Rename(logFileName, logFileName+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, logFileName, "line4\n")
+
+	var cursor Cursor
+	if err := cursor.UnmarshalText(token); err != nil {
+		t.Fatal(err)
+	}
+	r, err = fp.Resume(&cursor, logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read := parser.Slurp().String(); read != "line3\nline4\n" {
+		t.Fatalf("read '%s' not match expect '%s'", read, "line3\nline4\n")
+	}
+	if len(r) != 2 || r[1].Cursor.Offset() != 6 {
+		t.Fatalf("unexpected result %v", r)
+	}
+
+	// Resume never writes the posfile
+	if entries, _ := filepath.Glob(filepath.Join(tmpdir, "*-*")); len(entries) != 0 {
+		t.Fatalf("posfile should not be written: %v", entries)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"time"
 )
 
 var (
@@ -23,6 +24,10 @@ var (
 
 	// ErrTokenTooLong is returned when a token exceeds the maximum allowed size
 	ErrTokenTooLong = errors.New("reader: token too long")
+
+	// ErrStop can be returned by Callback.Parse to stop reading after the line.
+	// The position is advanced to the end of the line and is not counted in Parsed.Errors.
+	ErrStop = errors.New("stop reading")
 )
 
 type Callback interface {
@@ -30,6 +35,27 @@ type Callback interface {
 	Finish(duration float64)
 }
 
+// Meta describes where a line was read from
+type Meta struct {
+	FileName string
+	// Offset is the position of the head of the line in the file
+	Offset int64
+}
+
+// MetaCallback is an optional interface for Callback.
+// If the Callback implements it, ParseMeta is called instead of Parse.
+type MetaCallback interface {
+	ParseMeta(b []byte, meta Meta) error
+}
+
+// BatchCallback is an optional interface for Callback.
+// If the Callback implements it, ParseBatch is called once per buffer fill with the complete
+// lines in the buffer instead of calling Parse for each line.
+// The lines point into the read buffer and are only valid during the call.
+type BatchCallback interface {
+	ParseBatch(lines [][]byte) error
+}
+
 type Parser struct {
 	WorkDir             string
 	MaxReadSize         int64
@@ -39,9 +65,43 @@ type Parser struct {
 	Silent              bool
 	NoAutoCommitPosFile bool
 	ArchiveDir          string
-	posFile             *posFile
-	lastPos             int64
-	lastfStat           *fStat
+	// Workers runs the Callback in this many goroutines if more than 1.
+	// The Callback must be safe for concurrent use, lines are not passed in order.
+	// LTSVCallback, JSONCallback, LogFormatCallback and RegexpCallback reuse one record and are not.
+	// The position only advances over lines that every worker has processed.
+	Workers int
+	// Mmap maps regular files into memory and passes lines to the Callback without copying.
+	// Pipes and special files are read as usual. If the file is truncated during the scan (copytruncate),
+	// reading stops there and the next run starts from the truncated file as without Mmap.
+	Mmap bool
+	// ReadAhead reads the next buffer in a goroutine while the Callback processes the current one.
+	// It is skipped if the buffers do not fit in the limit of SetBufferPoolLimit.
+	ReadAhead bool
+	// StopAtStartSize stops reading at the size of the file observed at the start of the run.
+	// Data written during the run is left for the next run.
+	StopAtStartSize bool
+	// MaxDuration stops reading at the next line boundary once it has elapsed since the start of Parse.
+	// The position of the last line read is committed and the rest is read in the next run.
+	MaxDuration time.Duration
+	// RateLimiter limits the bytes and lines read per second. It can be shared by many Parsers.
+	RateLimiter *RateLimiter
+	// CheckpointBytes, CheckpointLines and CheckpointInterval write the posfile at a line boundary
+	// during the scan every so many bytes, lines or so much time. A Callback implementing Flusher
+	// is flushed before each checkpoint.
+	CheckpointBytes    int64
+	CheckpointLines    int
+	CheckpointInterval time.Duration
+	checkpointState    checkpointState
+	deadline           time.Time
+	posFile            *posFile
+	lastPos            int64
+	lastfStat          *fStat
+	scanFileName       string
+	scanBase           int64
+	scanErrors         int
+	scanMeta           MetaCallback
+	batchLines         [][]byte
+	stopped            bool
 }
 
 type Parsed struct {
@@ -50,6 +110,19 @@ type Parsed struct {
 	StartPos int64
 	EndPos   int64
 	Rows     int
+	// Errors is the number of errors returned by the Callback
+	Errors int
+	// Interrupted is true if reading stopped before the end of the file by ErrStop or MaxDuration
+	Interrupted bool
+	// Cursor is the position after this file, it can be committed later by Parser.Commit
+	Cursor *Cursor
+	// Rotated is true if this file was read from the start because a rotation was detected
+	Rotated bool
+	// Gap is true if lines before StartPos may not have been read: they were skipped by MaxReadSize,
+	// the file was truncated or the rotated file was not found
+	Gap bool
+	// Skipped is the number of bytes skipped by MaxReadSize
+	Skipped int64
 }
 
 // Parse creates a Parser and parses the specified log file using the provided position file and callback.
@@ -69,7 +142,8 @@ func Parse(posFileName, logFile string, cb Callback) error {
 	return err
 }
 
-func (parser *Parser) Parse(posFileName, logFile string) ([]Parsed, error) {
+// setDefaults fills the zero value options
+func (parser *Parser) setDefaults(logFile string) {
 	if parser.WorkDir == "" {
 		parser.WorkDir = os.TempDir()
 	}
@@ -90,17 +164,35 @@ func (parser *Parser) Parse(posFileName, logFile string) ([]Parsed, error) {
 	if parser.ArchiveDir == "" {
 		parser.ArchiveDir = filepath.Dir(logFile)
 	}
+}
+
+// posFilePath returns the per-user posfile path for posFileName in WorkDir
+func (parser *Parser) posFilePath(posFileName string) string {
 	curUser, _ := user.Current()
 	uid := "0"
 	if curUser != nil {
 		uid = curUser.Uid
 	}
+	return filepath.Join(parser.WorkDir, fmt.Sprintf("%s-%s", posFileName, uid))
+}
 
-	parser.posFile = newPosFile(filepath.Join(parser.WorkDir, fmt.Sprintf("%s-%s", posFileName, uid)))
+func (parser *Parser) Parse(posFileName, logFile string) ([]Parsed, error) {
+	parser.setDefaults(logFile)
+	parser.posFile = newPosFile(parser.posFilePath(posFileName))
 	lastPos, duration, lastFstat, err := parser.posFile.read()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load pos file :%v", err)
 	}
+	return parser.parse(logFile, lastPos, duration, lastFstat)
+}
+
+// parse reads logFile from lastPos of the file of lastFstat, following a rotation
+func (parser *Parser) parse(logFile string, lastPos int64, duration float64, lastFstat *fStat) ([]Parsed, error) {
+	parser.stopped = false
+	parser.deadline = time.Time{}
+	if parser.MaxDuration > 0 {
+		parser.deadline = time.Now().Add(parser.MaxDuration)
+	}
 
 	fstat, err := fileStat(logFile)
 	if err != nil {
@@ -108,21 +200,25 @@ func (parser *Parser) Parse(posFileName, logFile string) ([]Parsed, error) {
 	}
 	result := make([]Parsed, 0)
 	if fstat.isNotRotated(lastFstat) {
+		truncated := false
 		if fstat.Size < lastPos {
 			if !parser.Silent {
 				log.Println("Detect Truncate")
 			}
 			// file is truncated, reset lastPos
 			lastPos = 0
+			truncated = true
 		}
 		parsed, err := parser.parseFile(
 			logFile,
 			lastPos,
 			true,
+			fstat,
 		)
 		if err != nil {
 			return nil, err
 		}
+		parsed.Gap = parsed.Gap || truncated
 		result = append(result, *parsed)
 	} else {
 		// rotate found
@@ -137,10 +233,13 @@ func (parser *Parser) Parse(posFileName, logFile string) ([]Parsed, error) {
 				logFile,
 				0, // lastPos
 				true,
+				fstat,
 			)
 			if err != nil {
 				return nil, err
 			}
+			parsed.Rotated = true
+			parsed.Gap = true
 			result = append(result, *parsed)
 		} else {
 			// previous file
@@ -148,22 +247,32 @@ func (parser *Parser) Parse(posFileName, logFile string) ([]Parsed, error) {
 				lastFile,
 				lastPos,
 				false, // no update posfile
+				nil,
 			)
-			if err != nil {
+			prevFailed := err != nil
+			if prevFailed {
 				log.Printf("Could not parse previous file :%v", err)
 			}
 			if parsed != nil {
 				result = append(result, *parsed)
 			}
+			if parser.stopped {
+				// keep the position in the previous file
+				parser.Callback.Finish(duration)
+				return result, nil
+			}
 			// new file
 			parsed, err = parser.parseFile(
 				logFile,
 				0, // lastPos
 				true,
+				fstat,
 			)
 			if err != nil {
 				return nil, err
 			}
+			parsed.Rotated = true
+			parsed.Gap = parsed.Gap || prevFailed
 			result = append(result, *parsed)
 		}
 	}
@@ -183,15 +292,22 @@ func seekToPos(f io.Reader, pos int64) error {
 	return nil
 }
 
-func (parser *Parser) parseFile(logFile string, lastPos int64, newest bool) (*Parsed, error) {
+// parseFile reads logFile from lastPos. startStat is the stat of logFile at the start of the run,
+// it bounds the read with StopAtStartSize if it is still the same file.
+func (parser *Parser) parseFile(logFile string, lastPos int64, newest bool, startStat *fStat) (*Parsed, error) {
 
 	fstat, err := fileStat(logFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to inode of log file: %v", err)
 	}
+	if parser.StopAtStartSize && startStat != nil && startStat.Inode == fstat.Inode && startStat.Dev == fstat.Dev {
+		// the data written while a previous file was read is left for the next run
+		fstat.Size = min(fstat.Size, startStat.Size)
+	}
 	if !parser.Silent {
 		log.Printf("Analysis start logFile:%s lastPos:%d Size:%d", logFile, lastPos, fstat.Size)
 	}
+	readFrom := lastPos
 	if lastPos == 0 && fstat.Size > parser.MaxReadSize {
 		// first time and big logfile
 		lastPos = fstat.Size
@@ -207,26 +323,45 @@ func (parser *Parser) parseFile(logFile string, lastPos int64, newest bool) (*Pa
 		return nil, fmt.Errorf("failed to open log file :%v", err)
 	}
 	defer f.Close()
-	err = seekToPos(f, lastPos)
-	if err != nil {
-		return nil, fmt.Errorf("failed to seek log file :%v", err)
-	}
 
-	rows, read, err := parser.scanFile(f, newest)
-	if err != nil && err != io.EOF {
+	parser.scanFileName = logFile
+	parser.scanBase = lastPos
+	parser.scanErrors = 0
+	parser.startCheckpoint(fstat)
+	var rows int
+	var read int64
+	mapped := false
+	// -1 reads to the end of the file
+	endPos := int64(-1)
+	if parser.StopAtStartSize {
+		endPos = fstat.Size
+	}
+	if parser.Mmap {
+		rows, read, mapped, err = parser.scanMmap(f, lastPos, endPos, newest)
+	}
+	if !mapped {
+		err = seekToPos(f, lastPos)
+		if err != nil {
+			return nil, fmt.Errorf("failed to seek log file :%v", err)
+		}
+		var r io.Reader = f
+		if endPos >= 0 {
+			r = io.LimitReader(r, endPos-lastPos)
+		}
+		rows, read, err = parser.scanFile(r, newest)
+	}
+	if err == ErrStop {
+		parser.stopped = true
+	} else if err != nil && err != io.EOF {
 		return nil, fmt.Errorf("something wrong in parse log :%v", err)
 	}
 	curPos := lastPos + read
 
 	// update postion
-	if newest {
-		parser.lastPos = curPos
-		parser.lastfStat = fstat
-		if !parser.NoAutoCommitPosFile {
-			err = parser.posFile.write(curPos, fstat)
-			if err != nil {
-				return nil, fmt.Errorf("failed to update pos file :%v", err)
-			}
+	// a previous file is also recorded when stopped in it, it will be found by inode next time
+	if newest || parser.stopped {
+		if err := parser.updatePos(curPos, fstat); err != nil {
+			return nil, err
 		}
 	}
 
@@ -236,6 +371,12 @@ func (parser *Parser) parseFile(logFile string, lastPos int64, newest bool) (*Pa
 		StartPos: lastPos,
 		EndPos:   curPos,
 		Rows:     rows,
+		Errors:   parser.scanErrors,
+		Cursor:   newCursor(curPos, fstat),
+		Gap:      lastPos > readFrom,
+		Skipped:  lastPos - readFrom,
+
+		Interrupted: parser.stopped,
 	}
 	if !parser.Silent {
 		log.Printf("Analysis completed logFile:%s startPos:%d endPos:%d Rows:%d", logFile, lastPos, curPos, rows)
@@ -244,6 +385,20 @@ func (parser *Parser) parseFile(logFile string, lastPos int64, newest bool) (*Pa
 	return parsed, nil
 }
 
+// updatePos records the position and writes the posfile unless NoAutoCommitPosFile or resuming from a Cursor
+func (parser *Parser) updatePos(pos int64, fstat *fStat) error {
+	parser.lastPos = pos
+	parser.lastfStat = fstat
+	if parser.NoAutoCommitPosFile || parser.posFile == nil {
+		return nil
+	}
+	err := parser.posFile.write(pos, fstat)
+	if err != nil {
+		return fmt.Errorf("failed to update pos file :%v", err)
+	}
+	return nil
+}
+
 func (parser *Parser) CommitPosFile() error {
 	if parser.posFile == nil {
 		return nil
@@ -255,11 +410,150 @@ func (parser *Parser) CommitPosFile() error {
 	return nil
 }
 
-func (parser *Parser) scanFile(f io.Reader, newest bool) (int, int64, error) {
+// callLine passes a line at offset to the Callback
+func (parser *Parser) callLine(b []byte, offset int64) error {
+	if parser.scanMeta != nil {
+		return parser.scanMeta.ParseMeta(b, Meta{
+			FileName: parser.scanFileName,
+			Offset:   offset,
+		})
+	}
+	return parser.Callback.Parse(b)
+}
+
+// parseLine passes a line to the Callback. read is the number of bytes scanned before the line.
+// It returns true if the Callback asked to stop reading.
+func (parser *Parser) parseLine(b []byte, read int64) bool {
+	err := parser.callLine(b, parser.scanBase+read)
+	if err == ErrStop {
+		return true
+	}
+	if err != nil {
+		parser.scanErrors++
+		log.Printf("Failed to parse log :%v", err)
+	}
+	return false
+}
+
+// parseBatch passes lines to the BatchCallback.
+// It returns true if the Callback asked to stop reading.
+func (parser *Parser) parseBatch(bc BatchCallback, lines [][]byte) bool {
+	err := bc.ParseBatch(lines)
+	if err == ErrStop {
+		return true
+	}
+	if err != nil {
+		parser.scanErrors++
+		log.Printf("Failed to parse log :%v", err)
+	}
+	return false
+}
+
+// parseRest passes the final partial line of a previous file to the Callback
+func (parser *Parser) parseRest(bc BatchCallback, b []byte, read int64) bool {
+	if bc != nil {
+		return parser.parseBatch(bc, [][]byte{b})
+	}
+	return parser.parseLine(b, read)
+}
+
+// expired reports whether MaxDuration has elapsed since the start of Parse
+func (parser *Parser) expired() bool {
+	return !parser.deadline.IsZero() && time.Now().After(parser.deadline)
+}
+
+// parseRestParallel dispatches the final partial line of a previous file to the workers
+func (parser *Parser) parseRestParallel(pool *workerPool, bc BatchCallback, b []byte, read int64) bool {
+	if pool != nil {
+		return pool.dispatch(b, read)
+	}
+	return parser.parseRest(bc, b, read)
+}
+
+// startScan prepares the Callback interfaces and the workers for a scan
+func (parser *Parser) startScan() (*workerPool, BatchCallback) {
+	parser.scanMeta, _ = parser.Callback.(MetaCallback)
+	bc, _ := parser.Callback.(BatchCallback)
+	if bc != nil && parser.batchLines == nil {
+		parser.batchLines = make([][]byte, 0, 1024)
+	}
+	if parser.Workers > 1 {
+		return newWorkerPool(parser, parser.Workers), bc
+	}
+	return nil, bc
+}
+
+// finishScan waits for the workers. Only lines acknowledged by the workers are counted as read.
+func (parser *Parser) finishScan(pool *workerPool, scan *int, read *int64, err *error) {
+	if pool == nil {
+		return
+	}
+	rows, mark, stopped, errors := pool.wait()
+	*scan = rows
+	*read = mark
+	parser.scanErrors += errors
+	if stopped && (*err == nil || *err == io.EOF) {
+		*err = ErrStop
+	}
+}
+
+// scanLines passes the complete lines in buf to the Callback. read is the number of bytes scanned before buf.
+// It returns the length of the lines passed, the number of lines and whether to stop
+// because the Callback asked to or MaxDuration has elapsed.
+func (parser *Parser) scanLines(buf []byte, read int64, pool *workerPool, bc BatchCallback) (int, int, bool) {
+	if parser.expired() {
+		return 0, 0, true
+	}
+	if pool != nil {
+		// hand all complete lines to the workers at once
+		k := bytes.LastIndexByte(buf, '\n') + 1
+		if k == 0 {
+			return 0, 0, false
+		}
+		return k, 0, pool.dispatch(buf[0:k], read)
+	}
 	scan := 0
-	read := int64(0)
-	buf := make([]byte, parser.StartBufSize)
+	k := 0
+	lines := parser.batchLines[:0]
+	for {
+		idx := bytes.IndexByte(buf[k:], '\n')
+		if idx < 0 {
+			break
+		}
+		// found newline at k+idx
+		if bc != nil {
+			lines = append(lines, buf[k:k+idx])
+		} else if k > 0 && parser.expired() {
+			return k, scan, true
+		} else if parser.parseLine(buf[k:k+idx], read+int64(k)) {
+			return k + idx + 1, scan + 1, true
+		}
+		scan++
+		k += idx + 1
+	}
+	parser.batchLines = lines[:0]
+	if len(lines) > 0 && parser.parseBatch(bc, lines) {
+		return k, scan, true
+	}
+	return k, scan, false
+}
+
+func (parser *Parser) scanFile(f io.Reader, newest bool) (scan int, read int64, err error) {
+	// buffers are shared with other Parsers through the buffer pool
+	buf := defaultBufferPool.get(parser.StartBufSize)
+	defer func() {
+		defaultBufferPool.put(buf)
+	}()
+	if parser.ReadAhead {
+		// the read ahead buffers are taken after buf, they never wait for the limit
+		if ra := newReadAheadReader(f, len(buf)); ra != nil {
+			defer ra.Close()
+			f = ra
+		}
+	}
 	offset := 0
+	pool, bc := parser.startScan()
+	defer parser.finishScan(pool, &scan, &read, &err)
 	for {
 		nRead, err := f.Read(buf[offset:])
 		eof := false
@@ -276,11 +570,15 @@ func (parser *Parser) scanFile(f io.Reader, newest bool) (int, int64, error) {
 			// in the buffer (offset > 0), process it according to the 'newest' flag.
 			if offset > 0 {
 				if !newest {
-					read += int64(offset)
-					if err := parser.Callback.Parse(buf[0:offset]); err != nil {
-						log.Printf("Failed to parse log :%v", err)
+					if parser.expired() {
+						return scan, read, ErrStop
 					}
+					stop := parser.parseRestParallel(pool, bc, buf[0:offset], read)
+					read += int64(offset)
 					scan++
+					if stop {
+						return scan, read, ErrStop
+					}
 				}
 			}
 			return scan, read, io.EOF
@@ -289,20 +587,15 @@ func (parser *Parser) scanFile(f io.Reader, newest bool) (int, int64, error) {
 		n := nRead + offset
 
 		// scan lines within buf[0:n]
-		k := 0
-		for {
-			idx := bytes.IndexByte(buf[k:n], '\n')
-			if idx < 0 {
-				break
-			}
-			// found newline at k+idx
-			read += int64(idx + 1)
-			if err := parser.Callback.Parse(buf[k : k+idx]); err != nil {
-				log.Printf("Failed to parse log :%v", err)
-			}
-			scan++
-			k += idx + 1
+		// lines must be passed before the leftover is moved
+		k, rows, stop := parser.scanLines(buf[0:n], read, pool, bc)
+		read += int64(k)
+		scan += rows
+		if stop {
+			return scan, read, ErrStop
 		}
+		parser.throttle(nRead, parser.scannedLines(buf[0:k], rows, pool))
+		parser.checkpoint(pool, read, scan)
 
 		if k < n {
 			// remaining partial line in buffer
@@ -318,11 +611,15 @@ func (parser *Parser) scanFile(f io.Reader, newest bool) (int, int64, error) {
 			if offset > 0 {
 				if !newest {
 					// for rotated/old files, parse the final partial line
-					read += int64(offset)
-					if err := parser.Callback.Parse(buf[0:offset]); err != nil {
-						log.Printf("Failed to parse log :%v", err)
+					if parser.expired() {
+						return scan, read, ErrStop
 					}
+					stop := parser.parseRestParallel(pool, bc, buf[0:offset], read)
+					read += int64(offset)
 					scan++
+					if stop {
+						return scan, read, ErrStop
+					}
 				}
 			}
 			return scan, read, io.EOF
@@ -340,8 +637,10 @@ func (parser *Parser) scanFile(f io.Reader, newest bool) (int, int64, error) {
 				// expand buffer
 				newSize := len(buf) * 2
 				newSize = min(newSize, parser.MaxBufSize)
-				newBuf := make([]byte, newSize)
-				copy(newBuf, buf)
+				newBuf, err := defaultBufferPool.grow(buf, newSize)
+				if err != nil {
+					return scan, read, err
+				}
 				buf = newBuf
 			}
 			// continue reading into buffer at offset
@@ -3,6 +3,7 @@ package followparser
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -54,6 +55,10 @@ func benchScannerFile(b *testing.B, fname string) {
 }
 
 func benchScanFile(b *testing.B, fname string) {
+	benchScanFileWithCallback(b, fname, &dummyParser{})
+}
+
+func benchScanFileWithCallback(b *testing.B, fname string, cb Callback) {
 	b.ReportAllocs()
 
 	for i := 0; i < b.N; i++ {
@@ -61,9 +66,8 @@ func benchScanFile(b *testing.B, fname string) {
 		if err != nil {
 			b.Fatal(err)
 		}
-		parser := &dummyParser{}
 		p := &Parser{
-			Callback:     parser,
+			Callback:     cb,
 			StartBufSize: DefaultStartBufSize,
 			MaxBufSize:   DefaultMaxBufSize,
 			MaxReadSize:  DefaultMaxReadSize,
@@ -76,6 +80,31 @@ func benchScanFile(b *testing.B, fname string) {
 	}
 }
 
+func benchScanMmap(b *testing.B, fname string) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		fh, err := os.Open(fname)
+		if err != nil {
+			b.Fatal(err)
+		}
+		p := &Parser{
+			Callback:     &dummyParser{},
+			StartBufSize: DefaultStartBufSize,
+			MaxBufSize:   DefaultMaxBufSize,
+			MaxReadSize:  DefaultMaxReadSize,
+		}
+		_, _, mapped, err := p.scanMmap(fh, 0, -1, true)
+		if err != nil && err != io.EOF {
+			b.Fatal(err)
+		}
+		if !mapped {
+			b.Fatal("file must be mapped")
+		}
+		fh.Close()
+	}
+}
+
 func BenchmarkScanner_SmallLines(b *testing.B) {
 	dir := b.TempDir()
 	fname := filepath.Join(dir, "small.log")
@@ -99,6 +128,17 @@ func BenchmarkScanFile_SmallLines(b *testing.B) {
 	benchScanFile(b, fname)
 }
 
+func BenchmarkScanMmap_SmallLines(b *testing.B) {
+	dir := b.TempDir()
+	fname := filepath.Join(dir, "small.log")
+	line := "short line example\n"
+	if err := writeTestFile(fname, line, 10000); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	benchScanMmap(b, fname)
+}
+
 func BenchmarkScanner_LongLine(b *testing.B) {
 	dir := b.TempDir()
 	fname := filepath.Join(dir, "long.log")
@@ -122,6 +162,93 @@ func BenchmarkScanFile_LongLine(b *testing.B) {
 	benchScanFile(b, fname)
 }
 
+func BenchmarkScanMmap_LongLine(b *testing.B) {
+	dir := b.TempDir()
+	fname := filepath.Join(dir, "long.log")
+	longLine := string(bytes.Repeat([]byte("A"), DefaultStartBufSize+100)) + "\n"
+	if err := writeTestFile(fname, longLine, 1); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	benchScanMmap(b, fname)
+}
+
+// dummyBatchParser counts lines delivered by ParseBatch
+type dummyBatchParser struct {
+	dummyParser
+	lines int
+}
+
+func (p *dummyBatchParser) ParseBatch(lines [][]byte) error {
+	p.lines += len(lines)
+	return nil
+}
+
+func BenchmarkScanFile_SmallLinesBatch(b *testing.B) {
+	dir := b.TempDir()
+	fname := filepath.Join(dir, "small.log")
+	line := "short line example\n"
+	if err := writeTestFile(fname, line, 10000); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	benchScanFileWithCallback(b, fname, &dummyBatchParser{})
+}
+
+func BenchmarkScanFile_LongLineBatch(b *testing.B) {
+	dir := b.TempDir()
+	fname := filepath.Join(dir, "long.log")
+	longLine := string(bytes.Repeat([]byte("A"), DefaultStartBufSize+100)) + "\n"
+	if err := writeTestFile(fname, longLine, 1); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	benchScanFileWithCallback(b, fname, &dummyBatchParser{})
+}
+
+func BenchmarkScanFile_JSONLines(b *testing.B) {
+	dir := b.TempDir()
+	fname := filepath.Join(dir, "json.log")
+	line := `{"time":"2026-10-18T10:00:00+09:00","request":{"method":"GET","status":200,"path":"/foo/bar"},"latency_ms":12.5,"ua":"Mozilla/5.0"}` + "\n"
+	if err := writeTestFile(fname, line, 10000); err != nil {
+		b.Fatal(err)
+	}
+	cb := &JSONCallback{
+		Fields:  []string{"request.status", "latency_ms"},
+		Handler: func(_ *JSONRecord) error { return nil },
+	}
+	b.ResetTimer()
+	benchScanFileWithCallback(b, fname, cb)
+}
+
+func BenchmarkScanFile_JSONLinesStdlib(b *testing.B) {
+	dir := b.TempDir()
+	fname := filepath.Join(dir, "json.log")
+	line := `{"time":"2026-10-18T10:00:00+09:00","request":{"method":"GET","status":200,"path":"/foo/bar"},"latency_ms":12.5,"ua":"Mozilla/5.0"}` + "\n"
+	if err := writeTestFile(fname, line, 10000); err != nil {
+		b.Fatal(err)
+	}
+	cb := &stdlibJSONParser{}
+	b.ResetTimer()
+	benchScanFileWithCallback(b, fname, cb)
+}
+
+// stdlibJSONParser decodes the same fields as BenchmarkScanFile_JSONLines with encoding/json for comparison
+type stdlibJSONParser struct{}
+
+func (p *stdlibJSONParser) Parse(b []byte) error {
+	var v struct {
+		Request struct {
+			Status int `json:"status"`
+		} `json:"request"`
+		LatencyMs float64 `json:"latency_ms"`
+	}
+	return json.Unmarshal(b, &v)
+}
+
+func (p *stdlibJSONParser) Finish(_ float64) {
+}
+
 func (p *testParser) Parse(b []byte) error {
 	p.buf.Write(b)
 	p.buf.WriteString("\n")
@@ -612,3 +739,218 @@ func TestTruncated(t *testing.T) {
 		t.Fatalf("truncated rows must be 1 each %v", r2)
 	}
 }
+
+type testBatchParser struct {
+	testParser
+	batches int
+}
+
+func (p *testBatchParser) Parse(_ []byte) error {
+	return fmt.Errorf("Parse must not be called")
+}
+
+func (p *testBatchParser) ParseBatch(lines [][]byte) error {
+	p.batches++
+	for _, l := range lines {
+		p.buf.Write(l)
+		p.buf.WriteString("\n")
+	}
+	return nil
+}
+
+func TestParseBatch(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	expected := ""
+	for i := 0; i < 100; i++ {
+		expected += fmt.Sprintf("msg msg %08d\n", i)
+	}
+	// the last line without newline is left for the next run
+	if err := os.WriteFile(logFileName, []byte(expected+"partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	parser := &testBatchParser{testParser: testParser{buf: bytes.NewBufferString("")}}
+	fp := &Parser{
+		WorkDir:      tmpdir,
+		Callback:     parser,
+		Silent:       true,
+		StartBufSize: 100,
+	}
+	r, err := fp.Parse("logPosBatch", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := parser.Slurp().String()
+	if out != expected {
+		t.Fatalf("batch read '%s' not match expect '%s'", out, expected)
+	}
+	if parser.batches < 2 {
+		t.Errorf("lines must be delivered in multiple batches %d", parser.batches)
+	}
+	if len(r) != 1 || r[0].Rows != 100 || r[0].Errors != 0 {
+		t.Fatalf("result must be 1 file with 100 rows %v", r)
+	}
+	if r[0].EndPos != int64(len(expected)) {
+		t.Fatalf("EndPos must be %d %v", len(expected), r[0])
+	}
+}
+
+// appendingParser appends a line to the log file for each line it reads
+type appendingParser struct {
+	testParser
+	fh *os.File
+}
+
+func (p *appendingParser) Parse(b []byte) error {
+	if _, err := p.fh.WriteString("appended\n"); err != nil {
+		return err
+	}
+	return p.testParser.Parse(b)
+}
+
+func TestParseStopAtStartSize(t *testing.T) {
+	for _, mmap := range []bool{false, true} {
+		t.Run(fmt.Sprintf("mmap=%v", mmap), func(t *testing.T) {
+			tmpdir := t.TempDir()
+			logFileName := filepath.Join(tmpdir, "log")
+			expected := ""
+			for i := 0; i < 100; i++ {
+				expected += fmt.Sprintf("msg msg %08d\n", i)
+			}
+			if err := os.WriteFile(logFileName, []byte(expected), 0644); err != nil {
+				t.Fatal(err)
+			}
+			fh, err := os.OpenFile(logFileName, os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer fh.Close()
+
+			parser := &appendingParser{testParser: testParser{buf: bytes.NewBufferString("")}, fh: fh}
+			fp := &Parser{
+				WorkDir:         tmpdir,
+				Callback:        parser,
+				Silent:          true,
+				StartBufSize:    64,
+				Mmap:            mmap,
+				StopAtStartSize: true,
+			}
+			r, err := fp.Parse("logPosStopAtStartSize", logFileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out := parser.Slurp().String(); out != expected {
+				t.Fatalf("read length %d not match expect %d", len(out), len(expected))
+			}
+			if len(r) != 1 || r[0].Rows != 100 || r[0].EndPos != r[0].Size || r[0].Size != int64(len(expected)) {
+				t.Fatalf("EndPos must match Size %v", r)
+			}
+
+			// the appended lines are read in the next run
+			parser2 := &testParser{buf: bytes.NewBufferString("")}
+			fp2 := &Parser{WorkDir: tmpdir, Callback: parser2, Silent: true, StopAtStartSize: true}
+			r, err = fp2.Parse("logPosStopAtStartSize", logFileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(r) != 1 || r[0].Rows != 100 || r[0].EndPos != r[0].Size {
+				t.Fatalf("appended lines must be read %v", r)
+			}
+		})
+	}
+}
+
+func TestParseStopAtStartSizeRotated(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	appendFile(t, logFileName, "old1\n")
+	parser := &testParser{buf: bytes.NewBufferString("")}
+	fp := &Parser{WorkDir: tmpdir, Callback: parser, Silent: true, StopAtStartSize: true}
+	if _, err := fp.Parse("logPosStopAtStartSizeRotated", logFileName); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, logFileName, "old2\nold3\n")
+	if err := os.Rename(logFileName, logFileName+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, logFileName, "new1\n")
+	fh, err := os.OpenFile(logFileName, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+
+	// lines are appended to the new file while the previous file is read
+	parser2 := &appendingParser{testParser: testParser{buf: bytes.NewBufferString("")}, fh: fh}
+	fp = &Parser{WorkDir: tmpdir, Callback: parser2, Silent: true, StopAtStartSize: true}
+	r, err := fp.Parse("logPosStopAtStartSizeRotated", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "old2\nold3\nnew1\n"
+	if out := parser2.Slurp().String(); out != expected {
+		t.Fatalf("read '%s' not match expect '%s'", out, expected)
+	}
+	if len(r) != 2 || r[1].Rows != 1 || r[1].EndPos != 5 {
+		t.Fatalf("the new file must stop at its size at the start %v", r)
+	}
+}
+
+// slowParser sleeps for each line
+type slowParser struct {
+	testParser
+	finished bool
+}
+
+func (p *slowParser) Parse(b []byte) error {
+	time.Sleep(5 * time.Millisecond)
+	return p.testParser.Parse(b)
+}
+
+func (p *slowParser) Finish(duration float64) {
+	p.finished = true
+}
+
+func TestParseMaxDuration(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	expected := ""
+	for i := 0; i < 100; i++ {
+		expected += fmt.Sprintf("msg msg %08d\n", i)
+	}
+	if err := os.WriteFile(logFileName, []byte(expected), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	parser := &slowParser{testParser: testParser{buf: bytes.NewBufferString("")}}
+	fp := &Parser{WorkDir: tmpdir, Callback: parser, Silent: true, MaxDuration: 50 * time.Millisecond}
+	r, err := fp.Parse("logPosMaxDuration", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parser.finished {
+		t.Errorf("Finish must be called")
+	}
+	if len(r) != 1 || !r[0].Interrupted || r[0].Rows == 0 || r[0].Rows >= 100 {
+		t.Fatalf("parse must be interrupted %v", r)
+	}
+	first := parser.Slurp().String()
+	if r[0].EndPos != int64(len(first)) {
+		t.Fatalf("EndPos %d must be the end of the last line read %d", r[0].EndPos, len(first))
+	}
+
+	// the rest is read in the next run
+	parser2 := &testParser{buf: bytes.NewBufferString("")}
+	fp2 := &Parser{WorkDir: tmpdir, Callback: parser2, Silent: true, MaxDuration: time.Minute}
+	r, err = fp2.Parse("logPosMaxDuration", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := first + parser2.Slurp().String(); out != expected {
+		t.Fatalf("read '%s' not match expect '%s'", out, expected)
+	}
+	if len(r) != 1 || r[0].Interrupted {
+		t.Fatalf("parse must not be interrupted %v", r)
+	}
+}
@@ -81,13 +81,22 @@ func (pf *posFile) write(pos int64, fstat *fStat) error {
 	if err != nil {
 		return err
 	}
+	return writeFileAtomic(pf.filename, jb, 0600)
+}
+
+// writeFileAtomic replaces filename with data
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
 	// To avoid race condition, we create a temporary file and then rename it to the target filename.
-	f, err := os.CreateTemp(filepath.Dir(pf.filename), filepath.Base(pf.filename))
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename))
 	if err != nil {
 		return err
 	}
 	defer f.Close()
-	_, err = f.Write(jb)
+	err = f.Chmod(perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
 	if err != nil {
 		return err
 	}
@@ -99,7 +108,7 @@ func (pf *posFile) write(pos int64, fstat *fStat) error {
 	if err != nil {
 		return err
 	}
-	return os.Rename(f.Name(), pf.filename)
+	return os.Rename(f.Name(), filename)
 }
 
 func fileStat(filename string) (*fStat, error) {
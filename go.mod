@@ -3,3 +3,5 @@ module github.com/monitoring-forge/followparser
 go 1.25
 
 require github.com/avast/retry-go/v4 v4.7.0
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,458 @@
+package followparser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ErrInvalidJSON is returned when a line is not a valid JSON object
+var ErrInvalidJSON = errors.New("jsonl: invalid json")
+
+// maxJSONDepth limits nesting of objects and arrays in a line
+const maxJSONDepth = 64
+
+// JSONRecord holds the configured fields extracted from a JSON line.
+// String values are unquoted, other values are kept as raw JSON text.
+// Values point into the line buffer and are only valid during the handler call.
+type JSONRecord struct {
+	fields  []string
+	values  [][]byte
+	found   []bool
+	scratch []byte
+}
+
+// Len returns the number of configured fields
+func (r *JSONRecord) Len() int {
+	return len(r.fields)
+}
+
+// Value returns the value of the i-th configured field. ok is false if the field was not found.
+func (r *JSONRecord) Value(i int) ([]byte, bool) {
+	return r.values[i], r.found[i]
+}
+
+// Get returns the value for the configured path. ok is false if the path was not found.
+func (r *JSONRecord) Get(path string) ([]byte, bool) {
+	for i, f := range r.fields {
+		if f == path {
+			return r.values[i], r.found[i]
+		}
+	}
+	return nil, false
+}
+
+// Int returns the i-th configured field as an integer
+func (r *JSONRecord) Int(i int) (int64, bool) {
+	if !r.found[i] {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(string(r.values[i]), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// Float returns the i-th configured field as a float
+func (r *JSONRecord) Float(i int) (float64, bool) {
+	if !r.found[i] {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(string(r.values[i]), 64)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+func (r *JSONRecord) reset() {
+	for i := range r.values {
+		r.values[i] = nil
+		r.found[i] = false
+	}
+	r.scratch = r.scratch[:0]
+}
+
+// JSONCallback is a Callback that decodes each line as a JSON object,
+// extracts Fields into a reusable JSONRecord and passes it to Handler.
+// It is not safe for concurrent use, so it must not be used with Parser.Workers.
+type JSONCallback struct {
+	// Fields are dot separated paths to extract, such as "request.status"
+	Fields []string
+	// Handler is called for each decoded line
+	Handler func(r *JSONRecord) error
+	// OnFinish is called from Finish if set
+	OnFinish  func(duration float64)
+	paths     [][]string
+	cands     [][]int
+	record    JSONRecord
+	malformed int
+}
+
+// Malformed returns the number of lines that were not valid JSON
+func (c *JSONCallback) Malformed() int {
+	return c.malformed
+}
+
+// Parse decodes b and calls Handler. Empty lines are skipped.
+func (c *JSONCallback) Parse(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	if c.paths == nil {
+		c.init()
+	}
+	c.record.reset()
+	if err := c.decode(b); err != nil {
+		c.malformed++
+		return err
+	}
+	if c.Handler == nil {
+		return nil
+	}
+	return c.Handler(&c.record)
+}
+
+// Finish calls OnFinish
+func (c *JSONCallback) Finish(duration float64) {
+	if c.OnFinish != nil {
+		c.OnFinish(duration)
+	}
+}
+
+func (c *JSONCallback) init() {
+	c.paths = make([][]string, len(c.Fields))
+	for i, f := range c.Fields {
+		c.paths[i] = strings.Split(f, ".")
+	}
+	c.cands = make([][]int, maxJSONDepth)
+	c.record = JSONRecord{
+		fields: c.Fields,
+		values: make([][]byte, len(c.Fields)),
+		found:  make([]bool, len(c.Fields)),
+	}
+}
+
+func (c *JSONCallback) decode(b []byte) error {
+	i := skipJSONSpace(b, 0)
+	if i >= len(b) || b[i] != '{' {
+		return fmt.Errorf("%w: not an object", ErrInvalidJSON)
+	}
+	all := c.cands[0][:0]
+	for n := range c.paths {
+		all = append(all, n)
+	}
+	c.cands[0] = all
+	i, err := c.walkObject(b, i, all, 0)
+	if err != nil {
+		return err
+	}
+	if skipJSONSpace(b, i) != len(b) {
+		return fmt.Errorf("%w: trailing data at %d", ErrInvalidJSON, i)
+	}
+	return nil
+}
+
+// walkObject walks the object starting at b[i] and extracts values of cands.
+// cands are indexes of paths whose first depth elements match the current object.
+func (c *JSONCallback) walkObject(b []byte, i int, cands []int, depth int) (int, error) {
+	if depth >= maxJSONDepth-1 {
+		return 0, fmt.Errorf("%w: too deep", ErrInvalidJSON)
+	}
+	i = skipJSONSpace(b, i+1)
+	if i < len(b) && b[i] == '}' {
+		return i + 1, nil
+	}
+	for {
+		if i >= len(b) || b[i] != '"' {
+			return 0, fmt.Errorf("%w: expected key at %d", ErrInvalidJSON, i)
+		}
+		end, escaped, err := scanJSONString(b, i)
+		if err != nil {
+			return 0, err
+		}
+		key := b[i+1 : end-1]
+		if escaped && len(cands) > 0 {
+			key = c.unescape(key)
+		}
+		i = skipJSONSpace(b, end)
+		if i >= len(b) || b[i] != ':' {
+			return 0, fmt.Errorf("%w: expected colon at %d", ErrInvalidJSON, i)
+		}
+		i = skipJSONSpace(b, i+1)
+
+		// narrow down candidates by the key
+		next := c.cands[depth+1][:0]
+		for _, n := range cands {
+			p := c.paths[n]
+			if p[depth] != string(key) {
+				continue
+			}
+			if len(p) == depth+1 {
+				end, err := skipJSONValue(b, i, depth+1)
+				if err != nil {
+					return 0, err
+				}
+				c.record.values[n] = c.value(b[i:end])
+				c.record.found[n] = true
+				continue
+			}
+			next = append(next, n)
+		}
+		c.cands[depth+1] = next
+		if len(next) > 0 && i < len(b) && b[i] == '{' {
+			i, err = c.walkObject(b, i, next, depth+1)
+		} else {
+			i, err = skipJSONValue(b, i, depth+1)
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		i = skipJSONSpace(b, i)
+		if i >= len(b) {
+			return 0, fmt.Errorf("%w: unexpected end", ErrInvalidJSON)
+		}
+		switch b[i] {
+		case ',':
+			i = skipJSONSpace(b, i+1)
+		case '}':
+			return i + 1, nil
+		default:
+			return 0, fmt.Errorf("%w: unexpected %q at %d", ErrInvalidJSON, b[i], i)
+		}
+	}
+}
+
+// value unquotes string values and returns others as is
+func (c *JSONCallback) value(v []byte) []byte {
+	if len(v) == 0 || v[0] != '"' {
+		return v
+	}
+	s := v[1 : len(v)-1]
+	if bytes.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	return c.unescape(s)
+}
+
+// unescape decodes the escape sequences of a string validated by scanJSONString
+func (c *JSONCallback) unescape(s []byte) []byte {
+	start := len(c.record.scratch)
+	buf := c.record.scratch
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			buf = append(buf, s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'b':
+			buf = append(buf, '\b')
+		case 'f':
+			buf = append(buf, '\f')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'r':
+			buf = append(buf, '\r')
+		case 't':
+			buf = append(buf, '\t')
+		case 'u':
+			r := hexRune(s[i+1 : i+5])
+			i += 4
+			if utf16.IsSurrogate(r) && i+6 < len(s) && s[i+1] == '\\' && s[i+2] == 'u' {
+				if r2 := utf16.DecodeRune(r, hexRune(s[i+3:i+7])); r2 != utf8.RuneError {
+					r = r2
+					i += 6
+				}
+			}
+			buf = utf8.AppendRune(buf, r)
+		default:
+			buf = append(buf, s[i])
+		}
+	}
+	c.record.scratch = buf
+	return buf[start:]
+}
+
+func hexRune(h []byte) rune {
+	var r rune
+	for _, c := range h {
+		r <<= 4
+		switch {
+		case '0' <= c && c <= '9':
+			r |= rune(c - '0')
+		case 'a' <= c && c <= 'f':
+			r |= rune(c - 'a' + 10)
+		case 'A' <= c && c <= 'F':
+			r |= rune(c - 'A' + 10)
+		}
+	}
+	return r
+}
+
+func skipJSONSpace(b []byte, i int) int {
+	for i < len(b) {
+		switch b[i] {
+		case ' ', '\t', '\r', '\n':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// scanJSONString returns the index after the closing quote of the string starting at b[i]
+func scanJSONString(b []byte, i int) (int, bool, error) {
+	escaped := false
+	for j := i + 1; j < len(b); j++ {
+		switch b[j] {
+		case '"':
+			return j + 1, escaped, nil
+		case '\\':
+			escaped = true
+			j++
+			if j >= len(b) {
+				break
+			}
+			switch b[j] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+			case 'u':
+				if j+4 >= len(b) {
+					return 0, false, fmt.Errorf("%w: bad unicode escape at %d", ErrInvalidJSON, j)
+				}
+				for _, h := range b[j+1 : j+5] {
+					if !isHex(h) {
+						return 0, false, fmt.Errorf("%w: bad unicode escape at %d", ErrInvalidJSON, j)
+					}
+				}
+				j += 4
+			default:
+				return 0, false, fmt.Errorf("%w: bad escape at %d", ErrInvalidJSON, j)
+			}
+		default:
+			if b[j] < 0x20 {
+				return 0, false, fmt.Errorf("%w: control character in string at %d", ErrInvalidJSON, j)
+			}
+		}
+	}
+	return 0, false, fmt.Errorf("%w: unterminated string", ErrInvalidJSON)
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+// skipJSONValue validates the value starting at b[i] and returns the index after it
+func skipJSONValue(b []byte, i int, depth int) (int, error) {
+	if i >= len(b) {
+		return 0, fmt.Errorf("%w: unexpected end", ErrInvalidJSON)
+	}
+	if depth >= maxJSONDepth {
+		return 0, fmt.Errorf("%w: too deep", ErrInvalidJSON)
+	}
+	switch c := b[i]; {
+	case c == '"':
+		end, _, err := scanJSONString(b, i)
+		return end, err
+	case c == '{' || c == '[':
+		closer := byte('}')
+		if c == '[' {
+			closer = ']'
+		}
+		i = skipJSONSpace(b, i+1)
+		if i < len(b) && b[i] == closer {
+			return i + 1, nil
+		}
+		for {
+			var err error
+			if c == '{' {
+				if i >= len(b) || b[i] != '"' {
+					return 0, fmt.Errorf("%w: expected key at %d", ErrInvalidJSON, i)
+				}
+				i, _, err = scanJSONString(b, i)
+				if err != nil {
+					return 0, err
+				}
+				i = skipJSONSpace(b, i)
+				if i >= len(b) || b[i] != ':' {
+					return 0, fmt.Errorf("%w: expected colon at %d", ErrInvalidJSON, i)
+				}
+				i = skipJSONSpace(b, i+1)
+			}
+			i, err = skipJSONValue(b, i, depth+1)
+			if err != nil {
+				return 0, err
+			}
+			i = skipJSONSpace(b, i)
+			if i >= len(b) {
+				return 0, fmt.Errorf("%w: unexpected end", ErrInvalidJSON)
+			}
+			if b[i] == closer {
+				return i + 1, nil
+			}
+			if b[i] != ',' {
+				return 0, fmt.Errorf("%w: unexpected %q at %d", ErrInvalidJSON, b[i], i)
+			}
+			i = skipJSONSpace(b, i+1)
+		}
+	case c == '-' || ('0' <= c && c <= '9'):
+		return scanJSONNumber(b, i)
+	case c == 't':
+		return scanJSONLiteral(b, i, "true")
+	case c == 'f':
+		return scanJSONLiteral(b, i, "false")
+	case c == 'n':
+		return scanJSONLiteral(b, i, "null")
+	}
+	return 0, fmt.Errorf("%w: unexpected %q at %d", ErrInvalidJSON, b[i], i)
+}
+
+func scanJSONLiteral(b []byte, i int, lit string) (int, error) {
+	if len(b)-i < len(lit) || string(b[i:i+len(lit)]) != lit {
+		return 0, fmt.Errorf("%w: bad literal at %d", ErrInvalidJSON, i)
+	}
+	return i + len(lit), nil
+}
+
+func scanJSONNumber(b []byte, i int) (int, error) {
+	start := i
+	if b[i] == '-' {
+		i++
+	}
+	digits := func() int {
+		n := 0
+		for i < len(b) && '0' <= b[i] && b[i] <= '9' {
+			i++
+			n++
+		}
+		return n
+	}
+	intStart := i
+	if n := digits(); n == 0 || n > 1 && b[intStart] == '0' {
+		// no digits, or a leading zero
+		return 0, fmt.Errorf("%w: bad number at %d", ErrInvalidJSON, start)
+	}
+	if i < len(b) && b[i] == '.' {
+		i++
+		if digits() == 0 {
+			return 0, fmt.Errorf("%w: bad number at %d", ErrInvalidJSON, start)
+		}
+	}
+	if i < len(b) && (b[i] == 'e' || b[i] == 'E') {
+		i++
+		if i < len(b) && (b[i] == '+' || b[i] == '-') {
+			i++
+		}
+		if digits() == 0 {
+			return 0, fmt.Errorf("%w: bad number at %d", ErrInvalidJSON, start)
+		}
+	}
+	return i, nil
+}
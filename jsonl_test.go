@@ -0,0 +1,97 @@
+package followparser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJSONCallback(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "json.log")
+	content := `{"time":"2026-10-18T10:00:00","request":{"method":"GET","status":200,"path":"/a\"bé"},"latency_ms":1.5}` + "\n" +
+		`{"time":"2026-10-18T10:00:01","request":{"status":` + "\n" +
+		`{"request":{"status":503,"headers":{"x":[1,2,{"y":null}]}},"extra":[true,false]}` + "\n"
+	if err := os.WriteFile(logFileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	type row struct {
+		status  int64
+		path    string
+		latency float64
+		hasLat  bool
+	}
+	rows := []row{}
+	cb := &JSONCallback{
+		Fields: []string{"request.status", "request.path", "latency_ms"},
+		Handler: func(r *JSONRecord) error {
+			status, _ := r.Int(0)
+			path, _ := r.Get("request.path")
+			latency, ok := r.Float(2)
+			rows = append(rows, row{status, string(path), latency, ok})
+			return nil
+		},
+	}
+	fp := &Parser{WorkDir: tmpdir, Callback: cb, Silent: true}
+	r, err := fp.Parse("jsonPos", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 1 || r[0].Rows != 3 {
+		t.Fatalf("result must be 1 file with 3 rows %v", r)
+	}
+	if cb.Malformed() != 1 {
+		t.Errorf("malformed must be 1: %d", cb.Malformed())
+	}
+	if len(rows) != 2 {
+		t.Fatalf("handler must be called twice %v", rows)
+	}
+	if rows[0] != (row{200, "/a\"bé", 1.5, true}) {
+		t.Errorf("unexpected first row %v", rows[0])
+	}
+	if rows[1] != (row{503, "", 0, false}) {
+		t.Errorf("unexpected second row %v", rows[1])
+	}
+}
+
+func TestJSONCallbackInvalid(t *testing.T) {
+	invalids := []string{
+		`[1,2]`,
+		`{"a":1}x`,
+		`{"a":01x}`,
+		`{"a":01}`,
+		`{"a":-01.5}`,
+		`{"a":tru}`,
+		`{"a" 1}`,
+		`{"a":"\x"}`,
+		`{"a":{"b":1}`,
+	}
+	for _, in := range invalids {
+		cb := &JSONCallback{Fields: []string{"a.b"}}
+		err := cb.Parse([]byte(in))
+		if !errors.Is(err, ErrInvalidJSON) {
+			t.Errorf("%s must be ErrInvalidJSON: %v", in, err)
+		}
+	}
+}
+
+func TestJSONCallbackAllocs(t *testing.T) {
+	line := []byte(`{"time":"2026-10-18T10:00:00","request":{"method":"GET","status":200,"path":"/foo"},"latency_ms":12}`)
+	cb := &JSONCallback{
+		Fields:  []string{"request.status", "latency_ms"},
+		Handler: func(_ *JSONRecord) error { return nil },
+	}
+	if err := cb.Parse(line); err != nil {
+		t.Fatal(err)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := cb.Parse(line); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("JSONCallback.Parse must not allocate: %f", allocs)
+	}
+}
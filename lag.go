@@ -0,0 +1,96 @@
+package followparser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// lagSampleSize is the number of pending bytes read from each file to estimate the pending lines
+const lagSampleSize = 64 * 1024
+
+// Lag is how far a posfile is behind its log file
+type Lag struct {
+	// PendingBytes is the number of bytes not read yet, including the rest of a rotated file
+	PendingBytes int64
+	// PendingLines is estimated from a sample of the pending bytes. It is exact if the sample covers them.
+	PendingLines int64
+	// SinceCommit is the seconds since the posfile was written, zero if there is no posfile
+	SinceCommit float64
+	// Committed is false if there is no posfile yet
+	Committed bool
+	// RotationPending is true if the posfile points to a file that has been rotated
+	RotationPending bool
+	// RotatedFile is the rotated file found by inode, empty if it is not found in ArchiveDir
+	RotatedFile string
+}
+
+// Lag reports how far the posfile is behind logFile without reading lines or writing the posfile
+func (parser *Parser) Lag(posFileName, logFile string) (*Lag, error) {
+	parser.setDefaults(logFile)
+	lastPos, duration, lastFstat, err := newPosFile(parser.posFilePath(posFileName)).read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pos file :%v", err)
+	}
+	fstat, err := fileStat(logFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inode from log file :%v", err)
+	}
+	lag := &Lag{}
+	if lastFstat != nil {
+		lag.Committed = true
+		lag.SinceCommit = duration
+	}
+	startPos := int64(0)
+	if fstat.isNotRotated(lastFstat) {
+		if lastPos <= fstat.Size {
+			startPos = lastPos
+		}
+	} else {
+		lag.RotationPending = true
+		lastFile, err := lastFstat.searchFileByInode(parser.ArchiveDir)
+		if err == nil {
+			lag.RotatedFile = lastFile
+			if err := lag.addPending(lastFile, lastPos); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := lag.addPending(logFile, startPos); err != nil {
+		return nil, err
+	}
+	return lag, nil
+}
+
+// addPending adds the bytes and the estimated lines of logFile after pos
+func (lag *Lag) addPending(logFile string, pos int64) error {
+	f, err := os.Open(logFile)
+	if err != nil {
+		return fmt.Errorf("failed to open log file :%v", err)
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat log file :%v", err)
+	}
+	pending := st.Size() - pos
+	if pending <= 0 {
+		return nil
+	}
+	lag.PendingBytes += pending
+	buf := make([]byte, min(pending, lagSampleSize))
+	n, err := f.ReadAt(buf, pos)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read log file :%v", err)
+	}
+	if n == 0 {
+		return nil
+	}
+	lines := int64(bytes.Count(buf[:n], []byte{'\n'}))
+	if int64(n) < pending {
+		lines = lines * pending / int64(n)
+	}
+	lag.PendingLines += lines
+	return nil
+}
@@ -0,0 +1,74 @@
+package followparser
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLag(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	appendFile(t, logFileName, "line1\nline2\n")
+
+	fp := &Parser{WorkDir: tmpdir, Callback: &testParser{buf: bytes.NewBufferString("")}, Silent: true}
+	lag, err := fp.Lag("logPosLag", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lag.Committed || lag.PendingBytes != 12 || lag.PendingLines != 2 || lag.RotationPending {
+		t.Fatalf("unexpected lag without posfile %+v", lag)
+	}
+
+	if _, err := fp.Parse("logPosLag", logFileName); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, logFileName, "line3\nline4\npartial")
+	lag, err = fp.Lag("logPosLag", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lag.Committed || lag.PendingBytes != 19 || lag.PendingLines != 2 || lag.RotationPending {
+		t.Fatalf("unexpected lag %+v", lag)
+	}
+
+	// rotate
+	if err := os.Rename(logFileName, logFileName+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, logFileName, "line5\n")
+	lag, err = fp.Lag("logPosLag", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lag.RotationPending || lag.RotatedFile != logFileName+".1" || lag.PendingBytes != 25 || lag.PendingLines != 3 {
+		t.Fatalf("unexpected lag after rotation %+v", lag)
+	}
+
+	// Lag does not write the posfile
+	lastPos, _, _, err := newPosFile(fp.posFilePath("logPosLag")).read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastPos != 12 {
+		t.Fatalf("pos %d not match expect 12", lastPos)
+	}
+}
+
+func TestLagSampledLines(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	// 10000 lines of 16 bytes exceed the sample size
+	appendFile(t, logFileName, strings.Repeat("0123456789abcde\n", 10000))
+
+	fp := &Parser{WorkDir: tmpdir, Silent: true}
+	lag, err := fp.Lag("logPosLagSampled", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lag.PendingBytes != 160000 || lag.PendingLines != 10000 {
+		t.Fatalf("unexpected lag %+v", lag)
+	}
+}
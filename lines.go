@@ -0,0 +1,57 @@
+package followparser
+
+import "iter"
+
+// Line is a line yielded by Parser.Lines.
+// Bytes does not include the trailing newline and is only valid until the next iteration.
+type Line struct {
+	Meta
+	Bytes []byte
+}
+
+// Lines returns an iterator over the new lines of logFile, including the rest of a rotated file.
+// Parser.Callback is not used while iterating, and Workers is ignored so that lines are yielded in order.
+//
+// The posfile is committed when the iteration completes. Breaking out of the loop commits
+// the position of the last consumed line. With NoAutoCommitPosFile, the position is
+// committed only by CommitPosFile.
+// An error that stops the iteration is yielded with an empty Line.
+func (parser *Parser) Lines(posFileName, logFile string) iter.Seq2[Line, error] {
+	return func(yield func(Line, error) bool) {
+		cb, workers := parser.Callback, parser.Workers
+		defer func() {
+			parser.Callback, parser.Workers = cb, workers
+		}()
+		// yield must not be called concurrently
+		parser.Workers = 0
+		lc := &lineCallback{yield: yield}
+		parser.Callback = lc
+		_, err := parser.Parse(posFileName, logFile)
+		if err != nil && !lc.done {
+			yield(Line{}, err)
+		}
+	}
+}
+
+type lineCallback struct {
+	yield func(Line, error) bool
+	done  bool
+}
+
+func (c *lineCallback) Parse(b []byte) error {
+	return c.ParseMeta(b, Meta{})
+}
+
+func (c *lineCallback) ParseMeta(b []byte, meta Meta) error {
+	if c.done {
+		return ErrStop
+	}
+	if !c.yield(Line{Meta: meta, Bytes: b}, nil) {
+		c.done = true
+		return ErrStop
+	}
+	return nil
+}
+
+func (c *lineCallback) Finish(_ float64) {
+}
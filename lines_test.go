@@ -0,0 +1,144 @@
+package followparser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func collectLines(t *testing.T, fp *Parser, posFileName, logFile string, limit int) []string {
+	t.Helper()
+	lines := []string{}
+	for line, err := range fp.Lines(posFileName, logFile) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(line.Bytes))
+		if len(lines) == limit {
+			break
+		}
+	}
+	return lines
+}
+
+func TestLines(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	content := ""
+	for i := 0; i < 5; i++ {
+		content += fmt.Sprintf("msg msg %08d\n", i)
+	}
+	if err := os.WriteFile(logFileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fp := &Parser{WorkDir: tmpdir, Silent: true}
+	lines := collectLines(t, fp, "linesPos", logFileName, 2)
+	if fmt.Sprint(lines) != "[msg msg 00000000 msg msg 00000001]" {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+
+	// break leaves the posfile at the last consumed line
+	fp = &Parser{WorkDir: tmpdir, Silent: true}
+	offsets := []int64{}
+	for line, err := range fp.Lines("linesPos", logFileName) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		offsets = append(offsets, line.Offset)
+	}
+	if fmt.Sprint(offsets) != "[34 51 68]" {
+		t.Fatalf("unexpected offsets %v", offsets)
+	}
+
+	// nothing new
+	lines = collectLines(t, &Parser{WorkDir: tmpdir, Silent: true}, "linesPos", logFileName, 0)
+	if len(lines) != 0 {
+		t.Fatalf("lines must be empty %v", lines)
+	}
+}
+
+func TestLinesWorkers(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	content := ""
+	expected := []string{}
+	for i := 0; i < 1000; i++ {
+		content += fmt.Sprintf("msg msg %08d\n", i)
+		expected = append(expected, fmt.Sprintf("msg msg %08d", i))
+	}
+	if err := os.WriteFile(logFileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// lines are yielded in order even from many chunks
+	fp := &Parser{WorkDir: tmpdir, Silent: true, Workers: 4, StartBufSize: 64}
+	lines := collectLines(t, fp, "linesPos", logFileName, 0)
+	if fmt.Sprint(lines) != fmt.Sprint(expected) {
+		t.Fatalf("lines must be in order %v", lines)
+	}
+	if fp.Workers != 4 {
+		t.Fatalf("Workers must be restored: %d", fp.Workers)
+	}
+}
+
+func TestLinesBreakInRotatedFile(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	if err := os.WriteFile(logFileName, []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lines := collectLines(t, &Parser{WorkDir: tmpdir, Silent: true}, "linesRotatePos", logFileName, 0)
+	if fmt.Sprint(lines) != "[a]" {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+
+	f, err := os.OpenFile(logFileName, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("b\nc\n")
+	f.Close()
+	if err := os.Rename(logFileName, filepath.Join(tmpdir, "log.1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(logFileName, []byte("d\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// stop in the rotated file
+	lines = collectLines(t, &Parser{WorkDir: tmpdir, Silent: true}, "linesRotatePos", logFileName, 1)
+	if fmt.Sprint(lines) != "[b]" {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+	// continue from the rotated file
+	lines = collectLines(t, &Parser{WorkDir: tmpdir, Silent: true}, "linesRotatePos", logFileName, 0)
+	if fmt.Sprint(lines) != "[c d]" {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+}
+
+func TestLinesNoAutoCommitPosFile(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	if err := os.WriteFile(logFileName, []byte("a\nb\nc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fp := &Parser{WorkDir: tmpdir, Silent: true, NoAutoCommitPosFile: true}
+	lines := collectLines(t, fp, "linesNoCommitPos", logFileName, 1)
+	if fmt.Sprint(lines) != "[a]" {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+	lines = collectLines(t, fp, "linesNoCommitPos", logFileName, 1)
+	if fmt.Sprint(lines) != "[a]" {
+		t.Fatalf("position must not be committed %v", lines)
+	}
+	if err := fp.CommitPosFile(); err != nil {
+		t.Fatal(err)
+	}
+	lines = collectLines(t, fp, "linesNoCommitPos", logFileName, 0)
+	if fmt.Sprint(lines) != "[b c]" {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+}
@@ -0,0 +1,361 @@
+package followparser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// NginxCombinedFormat is the log_format of nginx "combined"
+	NginxCombinedFormat = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"`
+
+	// ApacheCommonFormat is the LogFormat of Apache "common"
+	ApacheCommonFormat = `%h %l %u %t "%r" %>s %b`
+
+	// ApacheCombinedFormat is the LogFormat of Apache "combined"
+	ApacheCombinedFormat = `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i"`
+)
+
+// ErrLogFormatMismatch is returned when a line does not match the log format
+var ErrLogFormatMismatch = errors.New("logformat: line does not match format")
+
+// apacheDirectives maps Apache LogFormat directives to nginx style field names
+var apacheDirectives = map[byte]string{
+	'a': "remote_addr",
+	'A': "server_addr",
+	'b': "body_bytes_sent",
+	'B': "body_bytes_sent",
+	'D': "request_time_us",
+	'f': "request_filename",
+	'h': "remote_host",
+	'H': "server_protocol",
+	'I': "bytes_received",
+	'k': "keepalive_requests",
+	'l': "remote_logname",
+	'L': "log_id",
+	'm': "request_method",
+	'O': "bytes_sent",
+	'p': "server_port",
+	'P': "pid",
+	'q': "query_string",
+	'r': "request",
+	'R': "handler",
+	's': "status",
+	'S': "bytes_transferred",
+	't': "time_local",
+	'T': "request_time",
+	'u': "remote_user",
+	'U': "uri",
+	'v': "server_name",
+	'V': "host",
+	'X': "connection_status",
+}
+
+// apacheBraceDirectives maps Apache %{Name}x directives to field name prefixes
+var apacheBraceDirectives = map[byte]string{
+	'i': "http_",
+	'o': "sent_http_",
+	'C': "cookie_",
+	'e': "env_",
+	'n': "note_",
+}
+
+type logFormatToken struct {
+	literal []byte
+	field   int
+	bracket bool
+	quoted  bool
+}
+
+// LogFormat is a compiled nginx log_format or Apache LogFormat
+type LogFormat struct {
+	names  []string
+	tokens []logFormatToken
+}
+
+// CompileNginxLogFormat compiles an nginx log_format string such as NginxCombinedFormat
+func CompileNginxLogFormat(format string) (*LogFormat, error) {
+	lf := &LogFormat{}
+	lit := []byte{}
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '$' {
+			lit = append(lit, c)
+			continue
+		}
+		var name string
+		if i+1 < len(format) && format[i+1] == '{' {
+			end := strings.IndexByte(format[i+2:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated variable at %d", i)
+			}
+			name = format[i+2 : i+2+end]
+			i += end + 2
+		} else {
+			j := i + 1
+			for j < len(format) && isVariableChar(format[j]) {
+				j++
+			}
+			name = format[i+1 : j]
+			i = j - 1
+		}
+		if name == "" {
+			return nil, fmt.Errorf("empty variable name at %d", i)
+		}
+		if err := lf.add(lit, name, false); err != nil {
+			return nil, err
+		}
+		lit = []byte{}
+	}
+	lf.addLiteral(lit)
+	lf.markQuoted()
+	return lf, nil
+}
+
+// CompileApacheLogFormat compiles an Apache LogFormat string such as ApacheCombinedFormat.
+// Field names follow the nginx variable names, for example %h is "remote_host",
+// %>s is "status" and %{User-agent}i is "http_user_agent".
+func CompileApacheLogFormat(format string) (*LogFormat, error) {
+	lf := &LogFormat{}
+	lit := []byte{}
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c == '\\' && i+1 < len(format) {
+			i++
+			switch format[i] {
+			case 't':
+				lit = append(lit, '\t')
+			case 'n':
+				lit = append(lit, '\n')
+			default:
+				lit = append(lit, format[i])
+			}
+			continue
+		}
+		if c != '%' {
+			lit = append(lit, c)
+			continue
+		}
+		start := i
+		i++
+		// skip modifiers such as "<", ">" and status code conditions
+		for i < len(format) && strings.IndexByte("<>!,0123456789", format[i]) >= 0 {
+			i++
+		}
+		if i >= len(format) {
+			return nil, fmt.Errorf("incomplete directive at %d", start)
+		}
+		if format[i] == '%' {
+			lit = append(lit, '%')
+			continue
+		}
+		arg := ""
+		hasArg := false
+		if format[i] == '{' {
+			end := strings.IndexByte(format[i+1:], '}')
+			if end < 0 || i+end+2 >= len(format) {
+				return nil, fmt.Errorf("unterminated directive at %d", start)
+			}
+			arg = format[i+1 : i+1+end]
+			hasArg = true
+			i += end + 2
+		}
+		d := format[i]
+		var name string
+		bracket := false
+		switch {
+		case hasArg && d == 't':
+			name = "time"
+		case hasArg:
+			prefix, ok := apacheBraceDirectives[d]
+			if !ok {
+				return nil, fmt.Errorf("unsupported directive %s", format[start:i+1])
+			}
+			name = prefix + strings.ToLower(strings.ReplaceAll(arg, "-", "_"))
+		default:
+			n, ok := apacheDirectives[d]
+			if !ok {
+				return nil, fmt.Errorf("unsupported directive %s", format[start:i+1])
+			}
+			name = n
+			bracket = d == 't'
+		}
+		if err := lf.add(lit, name, bracket); err != nil {
+			return nil, err
+		}
+		lit = []byte{}
+	}
+	lf.addLiteral(lit)
+	lf.markQuoted()
+	return lf, nil
+}
+
+func isVariableChar(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+func (lf *LogFormat) addLiteral(lit []byte) {
+	if len(lit) > 0 {
+		lf.tokens = append(lf.tokens, logFormatToken{literal: lit, field: -1})
+	}
+}
+
+func (lf *LogFormat) add(lit []byte, name string, bracket bool) error {
+	if len(lit) == 0 && len(lf.tokens) > 0 && lf.tokens[len(lf.tokens)-1].field >= 0 {
+		return fmt.Errorf("field %s must be separated from %s", name, lf.names[lf.tokens[len(lf.tokens)-1].field])
+	}
+	lf.addLiteral(lit)
+	lf.tokens = append(lf.tokens, logFormatToken{field: len(lf.names), bracket: bracket})
+	lf.names = append(lf.names, name)
+	return nil
+}
+
+// markQuoted marks fields enclosed in double quotes so that escaped quotes in values are skipped
+func (lf *LogFormat) markQuoted() {
+	for i := range lf.tokens {
+		if lf.tokens[i].field < 0 || i == 0 || i+1 == len(lf.tokens) {
+			continue
+		}
+		prev := lf.tokens[i-1].literal
+		next := lf.tokens[i+1].literal
+		lf.tokens[i].quoted = prev[len(prev)-1] == '"' && next[0] == '"'
+	}
+}
+
+// Fields returns the field names in the order they appear in the format
+func (lf *LogFormat) Fields() []string {
+	return lf.names
+}
+
+// Extract splits line into the fields of the format.
+// values is reused if it has enough capacity; returned values point into line.
+func (lf *LogFormat) Extract(line []byte, values [][]byte) ([][]byte, error) {
+	if cap(values) < len(lf.names) {
+		values = make([][]byte, len(lf.names))
+	}
+	values = values[:len(lf.names)]
+	pos := 0
+	for i, t := range lf.tokens {
+		if t.field < 0 {
+			if !bytes.HasPrefix(line[pos:], t.literal) {
+				return values, fmt.Errorf("%w: expected %q at %d", ErrLogFormatMismatch, t.literal, pos)
+			}
+			pos += len(t.literal)
+			continue
+		}
+		if t.bracket && pos < len(line) && line[pos] == '[' {
+			end := bytes.IndexByte(line[pos:], ']')
+			if end < 0 {
+				return values, fmt.Errorf("%w: unterminated [ at %d", ErrLogFormatMismatch, pos)
+			}
+			values[t.field] = line[pos+1 : pos+end]
+			pos += end + 1
+			continue
+		}
+		if i+1 == len(lf.tokens) {
+			values[t.field] = line[pos:]
+			pos = len(line)
+			continue
+		}
+		next := lf.tokens[i+1].literal
+		var end int
+		if t.quoted {
+			end = quotedEnd(line[pos:], next)
+		} else {
+			end = bytes.Index(line[pos:], next)
+		}
+		if end < 0 {
+			return values, fmt.Errorf("%w: expected %q after %d", ErrLogFormatMismatch, next, pos)
+		}
+		values[t.field] = line[pos : pos+end]
+		pos += end
+	}
+	if pos != len(line) {
+		return values, fmt.Errorf("%w: trailing data at %d", ErrLogFormatMismatch, pos)
+	}
+	return values, nil
+}
+
+// quotedEnd returns the index of the first unescaped quote that starts next
+func quotedEnd(b []byte, next []byte) int {
+	for j := 0; j < len(b); j++ {
+		switch b[j] {
+		case '\\':
+			j++
+		case '"':
+			if bytes.HasPrefix(b[j:], next) {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// LogFormatRecord is a line split into the fields of a LogFormat.
+// Values point into the line buffer and are only valid during the handler call.
+type LogFormatRecord struct {
+	format *LogFormat
+	values [][]byte
+}
+
+// Len returns the number of fields
+func (r *LogFormatRecord) Len() int {
+	return len(r.values)
+}
+
+// Name returns the name of the i-th field
+func (r *LogFormatRecord) Name(i int) string {
+	return r.format.names[i]
+}
+
+// Value returns the value of the i-th field
+func (r *LogFormatRecord) Value(i int) []byte {
+	return r.values[i]
+}
+
+// Get returns the value for name. ok is false if the format has no such field.
+func (r *LogFormatRecord) Get(name string) ([]byte, bool) {
+	for i, n := range r.format.names {
+		if n == name {
+			return r.values[i], true
+		}
+	}
+	return nil, false
+}
+
+// LogFormatCallback is a Callback that splits each line by Format and passes the record to Handler.
+// The record is reused for every line, so Parser.Workers must not be set.
+type LogFormatCallback struct {
+	Format *LogFormat
+	// Handler is called for each matched line
+	Handler func(r *LogFormatRecord) error
+	// OnFinish is called from Finish if set
+	OnFinish func(duration float64)
+	record   LogFormatRecord
+}
+
+// Parse splits b and calls Handler. Empty lines are skipped.
+func (c *LogFormatCallback) Parse(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	values, err := c.Format.Extract(b, c.record.values)
+	c.record.format = c.Format
+	c.record.values = values
+	if err != nil {
+		return err
+	}
+	if c.Handler == nil {
+		return nil
+	}
+	return c.Handler(&c.record)
+}
+
+// Finish calls OnFinish
+func (c *LogFormatCallback) Finish(duration float64) {
+	if c.OnFinish != nil {
+		c.OnFinish(duration)
+	}
+}
@@ -0,0 +1,118 @@
+package followparser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompileNginxLogFormat(t *testing.T) {
+	lf, err := CompileNginxLogFormat(NginxCombinedFormat + ` ${request_time}s`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := []byte(`192.0.2.1 - - [18/Oct/2026:10:00:00 +0900] "GET /a?b=\"c\" HTTP/1.1" 200 1234 "-" "Mozilla/5.0 (X11; Linux)" 0.012s`)
+	values, err := lf.Extract(line, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"remote_addr":     "192.0.2.1",
+		"remote_user":     "-",
+		"time_local":      "18/Oct/2026:10:00:00 +0900",
+		"request":         `GET /a?b=\"c\" HTTP/1.1`,
+		"status":          "200",
+		"body_bytes_sent": "1234",
+		"http_referer":    "-",
+		"http_user_agent": "Mozilla/5.0 (X11; Linux)",
+		"request_time":    "0.012",
+	}
+	for i, name := range lf.Fields() {
+		if string(values[i]) != expected[name] {
+			t.Errorf("%s: '%s' not match expect '%s'", name, values[i], expected[name])
+		}
+	}
+	if len(lf.Fields()) != len(expected) {
+		t.Errorf("unexpected fields %v", lf.Fields())
+	}
+}
+
+func TestCompileApacheLogFormat(t *testing.T) {
+	lf, err := CompileApacheLogFormat(`%h %l %u %t \"%r\" %>s %b \"%{Referer}i\" \"%{User-Agent}i\" %D`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := []byte(`192.0.2.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 [en] (Win98; I ;Nav)" 1500`)
+	values, err := lf.Extract(line, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"remote_host":     "192.0.2.1",
+		"remote_logname":  "-",
+		"remote_user":     "frank",
+		"time_local":      "10/Oct/2000:13:55:36 -0700",
+		"request":         "GET /apache_pb.gif HTTP/1.0",
+		"status":          "200",
+		"body_bytes_sent": "2326",
+		"http_referer":    "http://www.example.com/start.html",
+		"http_user_agent": "Mozilla/4.08 [en] (Win98; I ;Nav)",
+		"request_time_us": "1500",
+	}
+	for i, name := range lf.Fields() {
+		if string(values[i]) != expected[name] {
+			t.Errorf("%s: '%s' not match expect '%s'", name, values[i], expected[name])
+		}
+	}
+}
+
+func TestCompileLogFormatError(t *testing.T) {
+	if _, err := CompileNginxLogFormat(`$a$b`); err == nil {
+		t.Errorf("adjacent fields must be error")
+	}
+	if _, err := CompileNginxLogFormat(`${a`); err == nil {
+		t.Errorf("unterminated variable must be error")
+	}
+	if _, err := CompileApacheLogFormat(`%h %Z`); err == nil {
+		t.Errorf("unknown directive must be error")
+	}
+}
+
+func TestLogFormatCallback(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "access.log")
+	content := `192.0.2.1 - - [18/Oct/2026:10:00:00 +0900] "GET / HTTP/1.1" 200 10 "-" "curl/8.0"` + "\n" +
+		"garbage\n" +
+		`192.0.2.2 - - [18/Oct/2026:10:00:01 +0900] "POST /api HTTP/1.1" 502 0 "-" "curl/8.0"` + "\n"
+	if err := os.WriteFile(logFileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lf, err := CompileNginxLogFormat(NginxCombinedFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := []string{}
+	cb := &LogFormatCallback{
+		Format: lf,
+		Handler: func(r *LogFormatRecord) error {
+			s, _ := r.Get("status")
+			statuses = append(statuses, string(s))
+			return nil
+		},
+	}
+	if err := cb.Parse([]byte("garbage")); !errors.Is(err, ErrLogFormatMismatch) {
+		t.Errorf("must be ErrLogFormatMismatch %v", err)
+	}
+	fp := &Parser{WorkDir: tmpdir, Callback: cb, Silent: true}
+	r, err := fp.Parse("logFormatPos", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 1 || r[0].Rows != 3 {
+		t.Fatalf("result must be 1 file with 3 rows %v", r)
+	}
+	if len(statuses) != 2 || statuses[0] != "200" || statuses[1] != "502" {
+		t.Errorf("unexpected statuses %v", statuses)
+	}
+}
@@ -0,0 +1,122 @@
+package followparser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidLTSV is returned when a line contains a field without a label separator
+var ErrInvalidLTSV = errors.New("ltsv: malformed field")
+
+// LTSVRecord is a decoded LTSV line.
+// Labels and values point into the line buffer and are only valid during the handler call.
+type LTSVRecord struct {
+	labels [][]byte
+	values [][]byte
+}
+
+// Len returns the number of fields in the record
+func (r *LTSVRecord) Len() int {
+	return len(r.labels)
+}
+
+// Label returns the label of the i-th field
+func (r *LTSVRecord) Label(i int) []byte {
+	return r.labels[i]
+}
+
+// Value returns the value of the i-th field
+func (r *LTSVRecord) Value(i int) []byte {
+	return r.values[i]
+}
+
+// Get returns the value for label. ok is false if the label is not in the record.
+func (r *LTSVRecord) Get(label string) ([]byte, bool) {
+	for i, l := range r.labels {
+		if string(l) == label {
+			return r.values[i], true
+		}
+	}
+	return nil, false
+}
+
+func (r *LTSVRecord) reset() {
+	r.labels = r.labels[:0]
+	r.values = r.values[:0]
+}
+
+// LTSVCallback is a Callback that decodes each line as LTSV and passes the record to Handler.
+// The record is reused for every line, so Parser.Workers must not be set.
+type LTSVCallback struct {
+	// Labels restricts the record to these labels. All labels are kept if empty.
+	Labels []string
+	// Handler is called for each decoded line
+	Handler func(r *LTSVRecord) error
+	// OnFinish is called from Finish if set
+	OnFinish func(duration float64)
+	record   LTSVRecord
+}
+
+// Parse decodes b and calls Handler. Empty lines are skipped.
+func (c *LTSVCallback) Parse(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	if err := c.decode(b); err != nil {
+		return err
+	}
+	if c.Handler == nil {
+		return nil
+	}
+	return c.Handler(&c.record)
+}
+
+// Finish calls OnFinish
+func (c *LTSVCallback) Finish(duration float64) {
+	if c.OnFinish != nil {
+		c.OnFinish(duration)
+	}
+}
+
+func (c *LTSVCallback) decode(b []byte) error {
+	c.record.reset()
+	field := 0
+	for len(b) > 0 {
+		var f []byte
+		idx := bytes.IndexByte(b, '\t')
+		if idx < 0 {
+			f = b
+			b = nil
+		} else {
+			f = b[:idx]
+			b = b[idx+1:]
+		}
+		field++
+		if len(f) == 0 {
+			continue
+		}
+		sep := bytes.IndexByte(f, ':')
+		if sep < 0 {
+			return fmt.Errorf("%w at field %d", ErrInvalidLTSV, field)
+		}
+		if !c.wants(f[:sep]) {
+			continue
+		}
+		c.record.labels = append(c.record.labels, f[:sep])
+		c.record.values = append(c.record.values, f[sep+1:])
+	}
+	return nil
+}
+
+func (c *LTSVCallback) wants(label []byte) bool {
+	if len(c.Labels) == 0 {
+		return true
+	}
+	for _, l := range c.Labels {
+		if string(label) == l {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package followparser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLTSVCallback(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "ltsv.log")
+	content := "time:2026-10-18T10:00:00\tstatus:200\tpath:/foo\n" +
+		"broken line\n" +
+		"\n" +
+		"time:2026-10-18T10:00:01\tstatus:404\tpath:/bar?a=b:c\n"
+	if err := os.WriteFile(logFileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := []string{}
+	paths := []string{}
+	finished := false
+	cb := &LTSVCallback{
+		Labels: []string{"status", "path"},
+		Handler: func(r *LTSVRecord) error {
+			if r.Len() != 2 {
+				t.Errorf("record must have 2 fields %d", r.Len())
+			}
+			if _, ok := r.Get("time"); ok {
+				t.Errorf("time must not be extracted")
+			}
+			s, _ := r.Get("status")
+			p, _ := r.Get("path")
+			statuses = append(statuses, string(s))
+			paths = append(paths, string(p))
+			return nil
+		},
+		OnFinish: func(_ float64) {
+			finished = true
+		},
+	}
+	fp := &Parser{WorkDir: tmpdir, Callback: cb, Silent: true}
+	r, err := fp.Parse("ltsvPos", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 1 || r[0].Rows != 4 {
+		t.Fatalf("result must be 1 file with 4 rows %v", r)
+	}
+	if len(statuses) != 2 || statuses[0] != "200" || statuses[1] != "404" {
+		t.Errorf("unexpected statuses %v", statuses)
+	}
+	if len(paths) != 2 || paths[0] != "/foo" || paths[1] != "/bar?a=b:c" {
+		t.Errorf("unexpected paths %v", paths)
+	}
+	if !finished {
+		t.Errorf("OnFinish must be called")
+	}
+}
+
+func TestLTSVCallbackMalformed(t *testing.T) {
+	cb := &LTSVCallback{}
+	err := cb.Parse([]byte("a:1\tbroken\tc:3"))
+	if !errors.Is(err, ErrInvalidLTSV) {
+		t.Errorf("must be ErrInvalidLTSV %v", err)
+	}
+}
+
+func TestLTSVCallbackAllocs(t *testing.T) {
+	line := []byte("host:127.0.0.1\ttime:2026-10-18T10:00:00\tstatus:200\tsize:1234\treqtime:0.012")
+	cb := &LTSVCallback{
+		Handler: func(_ *LTSVRecord) error { return nil },
+	}
+	// warm up to grow the reusable record
+	if err := cb.Parse(line); err != nil {
+		t.Fatal(err)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := cb.Parse(line); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("LTSVCallback.Parse must not allocate: %f", allocs)
+	}
+}
@@ -0,0 +1,108 @@
+package followparser
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"runtime/debug"
+	"syscall"
+	"unsafe"
+)
+
+// scanMmap maps a regular file from lastPos to endPos, or to the end of the file if endPos is negative,
+// and scans it without copying.
+// mapped is false if the file is not a regular file or cannot be mapped,
+// the caller should fall back to scanFile then.
+func (parser *Parser) scanMmap(f *os.File, lastPos, endPos int64, newest bool) (int, int64, bool, error) {
+	st, err := f.Stat()
+	if err != nil || !st.Mode().IsRegular() {
+		return 0, 0, false, nil
+	}
+	size := st.Size()
+	if endPos >= 0 {
+		size = min(size, endPos)
+	}
+	if size <= lastPos {
+		return 0, 0, true, io.EOF
+	}
+	// the offset of mmap must be aligned to the page size
+	page := int64(os.Getpagesize())
+	aligned := lastPos - lastPos%page
+	m, err := syscall.Mmap(int(f.Fd()), aligned, int(size-aligned), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return 0, 0, false, nil
+	}
+	defer syscall.Munmap(m)
+	scan, read, err := parser.scanBytes(m[lastPos-aligned:], newest)
+	return scan, read, true, err
+}
+
+// scanBytes scans lines in data like scanFile. Lines are passed to the Callback
+// in windows of StartBufSize without copying.
+// If the file is truncated during the scan, it stops at the last window read, like at the end of the file.
+func (parser *Parser) scanBytes(data []byte, newest bool) (scan int, read int64, err error) {
+	// reading a mapped page past the end of a truncated file raises SIGBUS, make it a recoverable panic.
+	// Workers only get copies of the data, so this goroutine is the only one reading the mapping.
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer parser.recoverTruncate(data, &err)
+	pool, bc := parser.startScan()
+	defer parser.finishScan(pool, &scan, &read, &err)
+	size := int64(len(data))
+	window := int64(parser.StartBufSize)
+	for read < size {
+		buf := data[read:min(read+window, size)]
+		if bytes.IndexByte(buf, '\n') < 0 {
+			// the line is longer than the window
+			idx := bytes.IndexByte(data[read:], '\n')
+			if idx < 0 {
+				break
+			}
+			if idx+1 > parser.MaxBufSize {
+				return scan, read, ErrTokenTooLong
+			}
+			buf = data[read : read+int64(idx+1)]
+		}
+		k, rows, stop := parser.scanLines(buf, read, pool, bc)
+		read += int64(k)
+		scan += rows
+		if stop {
+			return scan, read, ErrStop
+		}
+		parser.throttle(k, parser.scannedLines(buf[0:k], rows, pool))
+		parser.checkpoint(pool, read, scan)
+	}
+	if rest := data[read:]; len(rest) > 0 && !newest {
+		// for rotated/old files, parse the final partial line
+		if parser.expired() {
+			return scan, read, ErrStop
+		}
+		stop := parser.parseRestParallel(pool, bc, rest, read)
+		read += int64(len(rest))
+		scan++
+		if stop {
+			return scan, read, ErrStop
+		}
+	}
+	return scan, read, io.EOF
+}
+
+// recoverTruncate recovers from a fault reading data and ends the scan with io.EOF.
+// Other panics are passed on.
+func (parser *Parser) recoverTruncate(data []byte, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	fault, ok := r.(interface{ Addr() uintptr })
+	start := uintptr(unsafe.Pointer(unsafe.SliceData(data)))
+	if !ok || fault.Addr() < start || fault.Addr() >= start+uintptr(len(data)) {
+		panic(r)
+	}
+	if !parser.Silent {
+		log.Println("Detect Truncate")
+	}
+	if *err != ErrStop {
+		*err = io.EOF
+	}
+}
@@ -0,0 +1,126 @@
+package followparser
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseMmap(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	first := ""
+	for i := 0; i < 1000; i++ {
+		first += fmt.Sprintf("msg msg %08d\n", i)
+	}
+	longLine := string(bytes.Repeat([]byte("A"), 300)) + "\n"
+	if err := os.WriteFile(logFileName, []byte(first+longLine+"partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	parser := &testParser{buf: bytes.NewBufferString("")}
+	fp := &Parser{WorkDir: tmpdir, Callback: parser, Silent: true, Mmap: true, StartBufSize: 100}
+	r, err := fp.Parse("logPosMmap", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := parser.Slurp().String(); out != first+longLine {
+		t.Fatalf("mmap read length %d not match expect %d", len(out), len(first+longLine))
+	}
+	if len(r) != 1 || r[0].Rows != 1001 || r[0].EndPos != int64(len(first+longLine)) {
+		t.Fatalf("unexpected result %v", r)
+	}
+
+	// continue from an unaligned position, then rotate
+	f, err := os.OpenFile(logFileName, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(" line\nlast")
+	f.Close()
+	if err := os.Rename(logFileName, filepath.Join(tmpdir, "log.1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(logFileName, []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parser = &testParser{buf: bytes.NewBufferString("")}
+	fp = &Parser{WorkDir: tmpdir, Callback: parser, Silent: true, Mmap: true}
+	r, err = fp.Parse("logPosMmap", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "partial line\nlast\nnew\n"
+	if out := parser.Slurp().String(); out != expected {
+		t.Fatalf("mmap rotate read '%s' not match expect '%s'", out, expected)
+	}
+	if len(r) != 2 || r[0].Rows != 2 || r[1].Rows != 1 {
+		t.Fatalf("unexpected result %v", r)
+	}
+}
+
+func TestScanMmapFallback(t *testing.T) {
+	rd, wr, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+	wr.WriteString("a\n")
+	wr.Close()
+	fp := &Parser{Callback: &dummyParser{}, StartBufSize: DefaultStartBufSize, MaxBufSize: DefaultMaxBufSize}
+	_, _, mapped, err := fp.scanMmap(rd, 0, -1, true)
+	if mapped || err != nil {
+		t.Fatalf("pipe must not be mapped: %v %v", mapped, err)
+	}
+}
+
+// truncateParser truncates the log file at the first line
+type truncateParser struct {
+	name  string
+	lines int
+}
+
+func (p *truncateParser) Parse(b []byte) error {
+	if p.lines == 0 {
+		if err := os.Truncate(p.name, 0); err != nil {
+			return err
+		}
+	}
+	p.lines++
+	return nil
+}
+
+func (p *truncateParser) Finish(_ float64) {
+}
+
+func TestParseMmapTruncate(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	line := string(bytes.Repeat([]byte("A"), 99)) + "\n"
+	if err := os.WriteFile(logFileName, bytes.Repeat([]byte(line), 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cb := &truncateParser{name: logFileName}
+	fp := &Parser{WorkDir: tmpdir, Callback: cb, Silent: true, Mmap: true, StartBufSize: 100}
+	r, err := fp.Parse("logPosMmapTruncate", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 1 || r[0].EndPos >= 100000 || cb.lines >= 1000 {
+		t.Fatalf("reading should stop at the truncation %v %d", r, cb.lines)
+	}
+
+	// the next run starts from the truncated file
+	appendFile(t, logFileName, "new\n")
+	parser := &testParser{buf: bytes.NewBufferString("")}
+	fp = &Parser{WorkDir: tmpdir, Callback: parser, Silent: true, Mmap: true}
+	if _, err := fp.Parse("logPosMmapTruncate", logFileName); err != nil {
+		t.Fatal(err)
+	}
+	if out := parser.Slurp().String(); out != "new\n" {
+		t.Fatalf("read '%s' not match expect '%s'", out, "new\n")
+	}
+}
@@ -0,0 +1,170 @@
+package followparser
+
+import (
+	"bytes"
+	"log"
+	"sync"
+)
+
+// chunk is a copy of complete lines handed to a worker
+type chunk struct {
+	seq  int
+	data []byte
+	// base is the number of bytes scanned before data
+	base int64
+}
+
+// workerPool runs the Callback for chunks in parallel.
+// The watermark is the end of the contiguous chunks that all workers have acknowledged.
+type workerPool struct {
+	parser  *Parser
+	ch      chan *chunk
+	free    chan []byte
+	wg      sync.WaitGroup
+	nextSeq int
+	bufs    int
+
+	mu        sync.Mutex
+	ackedSeq  int
+	pending   map[int]chunkAck
+	watermark int64
+	capped    bool
+	rows      int
+	errors    int
+	stopped   bool
+}
+
+// chunkAck is the result of a processed chunk
+type chunkAck struct {
+	end  int64
+	rows int
+	stop bool
+}
+
+func newWorkerPool(parser *Parser, workers int) *workerPool {
+	p := &workerPool{
+		parser:  parser,
+		ch:      make(chan *chunk, workers),
+		free:    make(chan []byte, workers*2),
+		pending: make(map[int]chunkAck),
+	}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+// dispatch copies data and queues it for the workers.
+// read is the number of bytes scanned before data.
+// It returns true if a worker asked to stop reading.
+func (p *workerPool) dispatch(data []byte, read int64) bool {
+	var buf []byte
+	if p.bufs < cap(p.free) {
+		select {
+		case buf = <-p.free:
+		default:
+			p.bufs++
+		}
+	} else {
+		// wait for a worker to release a buffer
+		buf = <-p.free
+	}
+	buf = append(buf[:0], data...)
+	p.ch <- &chunk{seq: p.nextSeq, data: buf, base: read}
+	p.nextSeq++
+	return p.isStopped()
+}
+
+func (p *workerPool) isStopped() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stopped
+}
+
+func (p *workerPool) work() {
+	defer p.wg.Done()
+	bc, _ := p.parser.Callback.(BatchCallback)
+	var lines [][]byte
+	for c := range p.ch {
+		rows := 0
+		errors := 0
+		read := int64(0)
+		stop := false
+		lines = lines[:0]
+		data := c.data
+		for len(data) > 0 && !stop {
+			line := data
+			size := len(data)
+			if idx := bytes.IndexByte(data, '\n'); idx >= 0 {
+				line = data[:idx]
+				size = idx + 1
+			}
+			if bc != nil {
+				lines = append(lines, line)
+			} else {
+				err := p.parser.callLine(line, p.parser.scanBase+c.base+read)
+				if err == ErrStop {
+					stop = true
+				} else if err != nil {
+					errors++
+					log.Printf("Failed to parse log :%v", err)
+				}
+			}
+			read += int64(size)
+			rows++
+			data = data[size:]
+		}
+		if len(lines) > 0 {
+			err := bc.ParseBatch(lines)
+			if err == ErrStop {
+				stop = true
+			} else if err != nil {
+				errors++
+				log.Printf("Failed to parse log :%v", err)
+			}
+		}
+		p.ack(c.seq, chunkAck{end: c.base + read, rows: rows, stop: stop}, errors)
+		p.free <- c.data
+	}
+}
+
+// ack records a processed chunk and advances the watermark over contiguous chunks.
+// The watermark never passes a chunk in which the Callback asked to stop.
+func (p *workerPool) ack(seq int, a chunkAck, errors int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.errors += errors
+	if a.stop {
+		p.stopped = true
+	}
+	p.pending[seq] = a
+	for !p.capped {
+		a, ok := p.pending[p.ackedSeq]
+		if !ok {
+			break
+		}
+		delete(p.pending, p.ackedSeq)
+		p.ackedSeq++
+		p.watermark = a.end
+		p.rows += a.rows
+		p.capped = a.stop
+	}
+}
+
+// wait waits for all dispatched chunks and returns the rows and the watermark,
+// whether a worker asked to stop and the number of errors
+func (p *workerPool) wait() (int, int64, bool, int) {
+	close(p.ch)
+	p.wg.Wait()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rows, p.watermark, p.stopped, p.errors
+}
+
+// position returns the rows and the watermark acknowledged so far
+func (p *workerPool) position() (int, int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rows, p.watermark
+}
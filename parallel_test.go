@@ -0,0 +1,129 @@
+package followparser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type syncTestParser struct {
+	mu     sync.Mutex
+	lines  []string
+	stopAt string
+}
+
+func (p *syncTestParser) Parse(b []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lines = append(p.lines, string(b))
+	if p.stopAt != "" && string(b) == p.stopAt {
+		return ErrStop
+	}
+	return nil
+}
+
+func (p *syncTestParser) Finish(_ float64) {
+}
+
+func (p *syncTestParser) sorted() []string {
+	sort.Strings(p.lines)
+	return p.lines
+}
+
+func TestParseWorkers(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	expected := []string{}
+	content := ""
+	for i := 0; i < 1000; i++ {
+		line := fmt.Sprintf("msg msg %08d", i)
+		expected = append(expected, line)
+		content += line + "\n"
+	}
+	if err := os.WriteFile(logFileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	parser := &syncTestParser{}
+	fp := &Parser{
+		WorkDir:      tmpdir,
+		Callback:     parser,
+		Silent:       true,
+		StartBufSize: 256,
+		Workers:      4,
+	}
+	r, err := fp.Parse("logPosWorkers", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(parser.sorted(), ",") != strings.Join(expected, ",") {
+		t.Fatalf("workers must read all lines")
+	}
+	if len(r) != 1 || r[0].Rows != 1000 || r[0].EndPos != int64(len(content)) {
+		t.Fatalf("unexpected result %v", r)
+	}
+}
+
+func TestParseWorkersStop(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	content := ""
+	for i := 0; i < 100; i++ {
+		content += fmt.Sprintf("msg msg %08d\n", i)
+	}
+	if err := os.WriteFile(logFileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	parser := &syncTestParser{stopAt: "msg msg 00000049"}
+	fp := &Parser{
+		WorkDir:      tmpdir,
+		Callback:     parser,
+		Silent:       true,
+		StartBufSize: 64,
+		Workers:      4,
+	}
+	r, err := fp.Parse("logPosWorkersStop", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 1 || r[0].Rows != 50 || r[0].EndPos != 50*17 {
+		t.Fatalf("position must stop after the stopped line %v", r)
+	}
+
+	// lines after the stopped line are read again
+	parser = &syncTestParser{}
+	fp = &Parser{WorkDir: tmpdir, Callback: parser, Silent: true, Workers: 4}
+	r, err = fp.Parse("logPosWorkersStop", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := parser.sorted()
+	if len(lines) != 50 || lines[0] != "msg msg 00000050" {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+	if len(r) != 1 || r[0].EndPos != int64(len(content)) {
+		t.Fatalf("unexpected result %v", r)
+	}
+}
+
+func TestWorkerPoolWatermark(t *testing.T) {
+	p := &workerPool{pending: make(map[int]chunkAck)}
+	p.ack(1, chunkAck{end: 20, rows: 2}, 0)
+	if p.watermark != 0 {
+		t.Fatalf("watermark must wait for chunk 0: %d", p.watermark)
+	}
+	p.ack(0, chunkAck{end: 10, rows: 1}, 0)
+	if p.watermark != 20 || p.rows != 3 {
+		t.Fatalf("watermark must be 20: %d rows %d", p.watermark, p.rows)
+	}
+	p.ack(3, chunkAck{end: 40, rows: 1}, 0)
+	p.ack(2, chunkAck{end: 25, rows: 1, stop: true}, 1)
+	if p.watermark != 25 || p.rows != 4 || !p.stopped || p.errors != 1 {
+		t.Fatalf("watermark must stop at 25: %d rows %d", p.watermark, p.rows)
+	}
+}
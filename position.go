@@ -0,0 +1,65 @@
+package followparser
+
+import (
+	"fmt"
+	"time"
+)
+
+// Position is the content of a posfile
+type Position struct {
+	// Path is the path of the posfile
+	Path string
+	// Exists is false if the posfile has not been written yet
+	Exists bool
+	Offset int64
+	Inode  uint64
+	Dev    uint64
+	// CommittedAt is the time the posfile was written
+	CommittedAt time.Time
+	// FileName is the file the position points to, logFile or a rotated file found by inode.
+	// It is empty if the file is not found.
+	FileName string
+}
+
+// Position reads the posfile of posFileName and finds the file it points to
+func (parser *Parser) Position(posFileName, logFile string) (*Position, error) {
+	parser.setDefaults(logFile)
+	path := parser.posFilePath(posFileName)
+	lastPos, duration, lastFstat, err := newPosFile(path).read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pos file :%v", err)
+	}
+	p := &Position{Path: path}
+	if lastFstat == nil {
+		return p, nil
+	}
+	p.Exists = true
+	p.Offset = lastPos
+	p.Inode = lastFstat.Inode
+	p.Dev = lastFstat.Dev
+	p.CommittedAt = time.Unix(time.Now().Unix()-int64(duration), 0)
+	if fstat, err := fileStat(logFile); err == nil && fstat.isNotRotated(lastFstat) {
+		p.FileName = logFile
+	} else if lastFile, err := lastFstat.searchFileByInode(parser.ArchiveDir); err == nil {
+		p.FileName = lastFile
+	}
+	return p, nil
+}
+
+// SetPosition writes the posfile of posFileName to offset in logFile.
+// The posfile is replaced atomically like a commit after Parse.
+func (parser *Parser) SetPosition(posFileName, logFile string, offset int64) error {
+	parser.setDefaults(logFile)
+	fstat, err := fileStat(logFile)
+	if err != nil {
+		return fmt.Errorf("failed to get inode from log file :%v", err)
+	}
+	if offset < 0 || offset > fstat.Size {
+		return fmt.Errorf("offset %d is out of the log file size %d", offset, fstat.Size)
+	}
+	err = newPosFile(parser.posFilePath(posFileName)).write(offset, fstat)
+	if err != nil {
+		return fmt.Errorf("failed to update pos file :%v", err)
+	}
+	return nil
+}
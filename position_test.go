@@ -0,0 +1,63 @@
+package followparser
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPosition(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	appendFile(t, logFileName, "line1\nline2\n")
+
+	fp := &Parser{WorkDir: tmpdir, Silent: true}
+	p, err := fp.Position("logPosPosition", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Exists || p.Path != fp.posFilePath("logPosPosition") {
+		t.Fatalf("unexpected position without posfile %+v", p)
+	}
+
+	if err := fp.SetPosition("logPosPosition", logFileName, 6); err != nil {
+		t.Fatal(err)
+	}
+	p, err = fp.Position("logPosPosition", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fstat, _ := fileStat(logFileName)
+	if !p.Exists || p.Offset != 6 || p.Inode != fstat.Inode || p.FileName != logFileName || time.Since(p.CommittedAt) > time.Minute {
+		t.Fatalf("unexpected position %+v", p)
+	}
+
+	// the position is read by Parse
+	parser := &testParser{buf: bytes.NewBufferString("")}
+	fp.Callback = parser
+	if _, err := fp.Parse("logPosPosition", logFileName); err != nil {
+		t.Fatal(err)
+	}
+	if read := parser.Slurp().String(); read != "line2\n" {
+		t.Fatalf("read '%s' not match expect '%s'", read, "line2\n")
+	}
+
+	// the rotated file is found by inode
+	if err := os.Rename(logFileName, logFileName+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, logFileName, "line3\n")
+	p, err = fp.Position("logPosPosition", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.FileName != logFileName+".1" || p.Offset != 12 {
+		t.Fatalf("unexpected position after rotation %+v", p)
+	}
+
+	if err := fp.SetPosition("logPosPosition", logFileName, 7); err == nil {
+		t.Fatal("offset beyond the file size should fail")
+	}
+}
@@ -0,0 +1,279 @@
+package followparser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultPromBuckets are the histogram buckets used when none are given
+var DefaultPromBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var promNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+const (
+	promCounter   = "counter"
+	promGauge     = "gauge"
+	promHistogram = "histogram"
+)
+
+// PromTextfile collects counters, gauges and histograms and writes them in the Prometheus text format
+// for the textfile collector of node_exporter. Counters and histograms are kept in a state file
+// across runs, gauges are only written for the current run.
+type PromTextfile struct {
+	// Path is the .prom file, it is replaced atomically
+	Path string
+	// StateFile keeps the counters and histograms across runs
+	StateFile string
+	metrics   map[string]*promMetric
+	err       error
+}
+
+type promMetric struct {
+	Help    string                 `json:"help"`
+	Type    string                 `json:"type"`
+	Buckets []float64              `json:"buckets,omitempty"`
+	Series  map[string]*promSeries `json:"series"`
+}
+
+// promSeries is a value of a metric for a set of labels.
+// Counts are the cumulative bucket counts of a histogram.
+type promSeries struct {
+	Value  float64  `json:"value"`
+	Counts []uint64 `json:"counts,omitempty"`
+	Count  uint64   `json:"count,omitempty"`
+}
+
+// NewPromTextfile returns a PromTextfile writing to path, with the counters of the previous runs loaded from stateFile
+func NewPromTextfile(path, stateFile string) (*PromTextfile, error) {
+	p := &PromTextfile{Path: path, StateFile: stateFile, metrics: map[string]*promMetric{}}
+	b, err := os.ReadFile(stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prometheus state :%v", err)
+	}
+	if err := json.Unmarshal(b, &p.metrics); err != nil {
+		return nil, fmt.Errorf("failed to load prometheus state :%v", err)
+	}
+	for name, m := range p.metrics {
+		for _, s := range m.Series {
+			if m.Type == promHistogram && len(s.Counts) != len(m.Buckets) {
+				return nil, fmt.Errorf("failed to load prometheus state :%s has %d bucket counts for %d buckets", name, len(s.Counts), len(m.Buckets))
+			}
+		}
+	}
+	return p, nil
+}
+
+// NewPromTextfile returns a PromTextfile writing to path, with the state file stored next to the posfile of posFileName
+func (parser *Parser) NewPromTextfile(posFileName, path string) (*PromTextfile, error) {
+	if parser.WorkDir == "" {
+		parser.WorkDir = os.TempDir()
+	}
+	return NewPromTextfile(path, parser.posFilePath(posFileName)+".promstate")
+}
+
+// AddCounter adds v to a counter. labels are pairs of label names and values.
+func (p *PromTextfile) AddCounter(name, help string, v float64, labels ...string) {
+	if s := p.series(name, help, promCounter, nil, labels); s != nil {
+		s.Value += v
+	}
+}
+
+// SetGauge sets a gauge. labels are pairs of label names and values.
+func (p *PromTextfile) SetGauge(name, help string, v float64, labels ...string) {
+	if s := p.series(name, help, promGauge, nil, labels); s != nil {
+		s.Value = v
+	}
+}
+
+// Observe adds v to a histogram with buckets, DefaultPromBuckets if nil.
+// labels are pairs of label names and values.
+func (p *PromTextfile) Observe(name, help string, buckets []float64, v float64, labels ...string) {
+	if buckets == nil {
+		buckets = DefaultPromBuckets
+	}
+	s := p.series(name, help, promHistogram, buckets, labels)
+	if s == nil {
+		return
+	}
+	if s.Counts == nil {
+		s.Counts = make([]uint64, len(buckets))
+	}
+	for i, le := range buckets {
+		if v <= le {
+			s.Counts[i]++
+		}
+	}
+	s.Value += v
+	s.Count++
+}
+
+// AddParsed adds the self metrics of a run: bytes read, rows, rotations and gaps
+func (p *PromTextfile) AddParsed(parsed []Parsed) {
+	var bytes, rows, rotations, gaps, skipped float64
+	for _, r := range parsed {
+		bytes += float64(r.EndPos - r.StartPos)
+		rows += float64(r.Rows)
+		skipped += float64(r.Skipped)
+		if r.Rotated {
+			rotations++
+		}
+		if r.Gap {
+			gaps++
+		}
+	}
+	p.AddCounter("followparser_read_bytes_total", "Bytes read by followparser.", bytes)
+	p.AddCounter("followparser_rows_total", "Lines read by followparser.", rows)
+	p.AddCounter("followparser_rotations_total", "Rotations detected by followparser.", rotations)
+	p.AddCounter("followparser_gaps_total", "Runs in which lines may have been skipped.", gaps)
+	p.AddCounter("followparser_skipped_bytes_total", "Bytes skipped by MaxReadSize.", skipped)
+}
+
+// series returns the series of name for labels, creating the metric if needed.
+// It records an error and returns nil if the name, the labels or the type are invalid.
+func (p *PromTextfile) series(name, help, typ string, buckets []float64, labels []string) *promSeries {
+	if p.err != nil {
+		return nil
+	}
+	if !promNameRegexp.MatchString(name) {
+		p.err = fmt.Errorf("invalid metric name %q", name)
+		return nil
+	}
+	key, err := promLabels(labels)
+	if err != nil {
+		p.err = fmt.Errorf("invalid labels of %s :%v", name, err)
+		return nil
+	}
+	m, ok := p.metrics[name]
+	if !ok {
+		m = &promMetric{Help: help, Type: typ, Buckets: buckets, Series: map[string]*promSeries{}}
+		p.metrics[name] = m
+	}
+	if m.Type != typ {
+		p.err = fmt.Errorf("metric %s is a %s, not a %s", name, m.Type, typ)
+		return nil
+	}
+	if !slices.Equal(m.Buckets, buckets) {
+		p.err = fmt.Errorf("metric %s has the buckets %v, not %v", name, m.Buckets, buckets)
+		return nil
+	}
+	m.Help = help
+	s, ok := m.Series[key]
+	if !ok {
+		s = &promSeries{}
+		m.Series[key] = s
+	}
+	return s
+}
+
+// promLabels formats label pairs sorted by name
+func promLabels(labels []string) (string, error) {
+	if len(labels)%2 != 0 {
+		return "", errors.New("labels must be pairs of names and values")
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i < len(labels); i += 2 {
+		if !promNameRegexp.MatchString(labels[i]) || strings.Contains(labels[i], ":") || labels[i] == "le" {
+			return "", fmt.Errorf("invalid label name %q", labels[i])
+		}
+		pairs = append(pairs, labels[i]+`="`+promEscape(labels[i+1])+`"`)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ","), nil
+}
+
+func promEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func promFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// withLabel appends a label to formatted labels
+func withLabel(labels, label string) string {
+	if labels == "" {
+		return label
+	}
+	return labels + "," + label
+}
+
+func promSample(b *strings.Builder, name, labels string, v string) {
+	b.WriteString(name)
+	if labels != "" {
+		b.WriteString("{" + labels + "}")
+	}
+	b.WriteString(" " + v + "\n")
+}
+
+// Text returns the metrics in the Prometheus text format
+func (p *PromTextfile) Text() string {
+	names := make([]string, 0, len(p.metrics))
+	for name := range p.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		m := p.metrics[name]
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, strings.ReplaceAll(m.Help, "\n", `\n`), name, m.Type)
+		keys := make([]string, 0, len(m.Series))
+		for key := range m.Series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := m.Series[key]
+			if m.Type != promHistogram {
+				promSample(&b, name, key, promFloat(s.Value))
+				continue
+			}
+			for i, le := range m.Buckets {
+				promSample(&b, name+"_bucket", withLabel(key, `le="`+promFloat(le)+`"`), strconv.FormatUint(s.Counts[i], 10))
+			}
+			promSample(&b, name+"_bucket", withLabel(key, `le="+Inf"`), strconv.FormatUint(s.Count, 10))
+			promSample(&b, name+"_sum", key, promFloat(s.Value))
+			promSample(&b, name+"_count", key, strconv.FormatUint(s.Count, 10))
+		}
+	}
+	return b.String()
+}
+
+// Write replaces Path atomically and saves the counters to StateFile
+func (p *PromTextfile) Write() error {
+	if p.err != nil {
+		return p.err
+	}
+	// gauges are not kept across runs
+	state := map[string]*promMetric{}
+	for name, m := range p.metrics {
+		if m.Type != promGauge {
+			state[name] = m
+		}
+	}
+	jb, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	// the state is saved last, so that a failed Write does not count the lines again when they are read again
+	if err := writeFileAtomic(p.Path, []byte(p.Text()), 0644); err != nil {
+		return fmt.Errorf("failed to write prometheus textfile :%v", err)
+	}
+	if err := writeFileAtomic(p.StateFile, jb, 0600); err != nil {
+		return fmt.Errorf("failed to save prometheus state :%v", err)
+	}
+	return nil
+}
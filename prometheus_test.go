@@ -0,0 +1,171 @@
+package followparser
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPromTextfile(t *testing.T) {
+	tmpdir := t.TempDir()
+	promFile := filepath.Join(tmpdir, "access.prom")
+	stateFile := filepath.Join(tmpdir, "access.promstate")
+
+	for run := 1; run <= 2; run++ {
+		p, err := NewPromTextfile(promFile, stateFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.AddCounter("requests_total", "Requests.", 2, "status", "200", "method", "GET")
+		p.AddCounter("requests_total", "Requests.", 1, "status", "5\"0\\0\n")
+		p.SetGauge("last_run", "Last run.", float64(run))
+		p.Observe("duration_seconds", "Durations.", []float64{0.1, 1}, 0.05)
+		p.Observe("duration_seconds", "Durations.", []float64{0.1, 1}, 0.5)
+		if err := p.Write(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := os.ReadFile(promFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP duration_seconds Durations.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{le="0.1"} 2
+duration_seconds_bucket{le="1"} 4
+duration_seconds_bucket{le="+Inf"} 4
+duration_seconds_sum 1.1
+duration_seconds_count 4
+# HELP last_run Last run.
+# TYPE last_run gauge
+last_run 2
+# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{method="GET",status="200"} 4
+requests_total{status="5\"0\\0\n"} 2
+`
+	if string(b) != expected {
+		t.Fatalf("read '%s' not match expect '%s'", b, expected)
+	}
+	if st, err := os.Stat(promFile); err != nil || st.Mode().Perm() != 0644 {
+		t.Fatalf("unexpected mode of textfile %v %v", st, err)
+	}
+	if entries, _ := filepath.Glob(filepath.Join(tmpdir, "*.tmp*")); len(entries) != 0 {
+		t.Fatalf("temporary files are left: %v", entries)
+	}
+	if state, _ := os.ReadFile(stateFile); bytes.Contains(state, []byte("last_run")) {
+		t.Fatalf("gauges should not be kept: %s", state)
+	}
+}
+
+func TestPromTextfileInvalid(t *testing.T) {
+	tmpdir := t.TempDir()
+	for name, add := range map[string]func(p *PromTextfile){
+		"name":  func(p *PromTextfile) { p.AddCounter("bad-name", "", 1) },
+		"label": func(p *PromTextfile) { p.AddCounter("ok", "", 1, "le", "1") },
+		"pairs": func(p *PromTextfile) { p.AddCounter("ok", "", 1, "status") },
+		"type": func(p *PromTextfile) {
+			p.AddCounter("ok", "", 1)
+			p.SetGauge("ok", "", 1)
+		},
+	} {
+		p, err := NewPromTextfile(filepath.Join(tmpdir, name+".prom"), filepath.Join(tmpdir, name+".promstate"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		add(p)
+		if err := p.Write(); err == nil {
+			t.Fatalf("%s: Write should fail", name)
+		}
+		if _, err := os.Stat(p.Path); err == nil {
+			t.Fatalf("%s: textfile should not be written", name)
+		}
+	}
+}
+
+func TestPromTextfileBuckets(t *testing.T) {
+	tmpdir := t.TempDir()
+	promFile := filepath.Join(tmpdir, "lat.prom")
+	stateFile := filepath.Join(tmpdir, "lat.promstate")
+	p, err := NewPromTextfile(promFile, stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Observe("lat", "h", nil, 0.1, "a", "x")
+	if err := p.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	// other buckets for a loaded histogram fail like a type conflict
+	p, err = NewPromTextfile(promFile, stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Observe("lat", "h", []float64{1, 2}, 0.1, "a", "y")
+	if err := p.Write(); err == nil || !strings.Contains(err.Error(), "buckets") {
+		t.Fatalf("Write should fail with the buckets: %v", err)
+	}
+
+	// bucket counts not matching the buckets in the state file
+	state := `{"lat":{"help":"h","type":"histogram","buckets":[1,2],"series":{"":{"value":1,"counts":[1],"count":1}}}}`
+	if err := os.WriteFile(stateFile, []byte(state), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewPromTextfile(promFile, stateFile); err == nil {
+		t.Fatal("a broken state file should fail")
+	}
+}
+
+func TestPromTextfileParsed(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	promFile := filepath.Join(tmpdir, "log.prom")
+	appendFile(t, logFileName, "line1\nline2\n")
+
+	parser := &testParser{buf: bytes.NewBufferString("")}
+	fp := &Parser{WorkDir: tmpdir, Callback: parser, Silent: true}
+	parse := func() {
+		t.Helper()
+		r, err := fp.Parse("logPos", logFileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := fp.NewPromTextfile("logPos", promFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.AddParsed(r)
+		if err := p.Write(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parse()
+	appendFile(t, logFileName, "line3\n")
+	if err := os.Rename(logFileName, logFileName+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, logFileName, "line4\n")
+	parse()
+
+	if _, err := os.Stat(fp.posFilePath("logPos") + ".promstate"); err != nil {
+		t.Fatalf("state file should be next to the posfile: %v", err)
+	}
+	b, err := os.ReadFile(promFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"followparser_read_bytes_total 24\n",
+		"followparser_rows_total 4\n",
+		"followparser_rotations_total 1\n",
+		"followparser_gaps_total 0\n",
+		"followparser_skipped_bytes_total 0\n",
+	} {
+		if !strings.Contains(string(b), line) {
+			t.Fatalf("'%s' not found in '%s'", line, b)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package followparser
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits bytes and lines read per second with token buckets.
+// A RateLimiter can be shared by many Parsers to limit the total rate of the process.
+type RateLimiter struct {
+	mu    sync.Mutex
+	bytes *tokenBucket
+	lines *tokenBucket
+}
+
+// NewRateLimiter returns a RateLimiter. A rate of zero or less is not limited.
+// Each bucket allows a burst of one second.
+func NewRateLimiter(bytesPerSec, linesPerSec float64) *RateLimiter {
+	now := time.Now()
+	return &RateLimiter{
+		bytes: newTokenBucket(bytesPerSec, now),
+		lines: newTokenBucket(linesPerSec, now),
+	}
+}
+
+// reserve takes bytes and lines from the buckets and returns how long to wait for them
+func (l *RateLimiter) reserve(nBytes, nLines int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	return max(l.bytes.reserve(now, float64(nBytes)), l.lines.reserve(now, float64(nLines)))
+}
+
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, now time.Time) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	return &tokenBucket{rate: rate, tokens: rate, last: now}
+}
+
+// reserve takes n tokens. Tokens can be borrowed, the debt is returned as the time to wait.
+func (b *tokenBucket) reserve(now time.Time, n float64) time.Duration {
+	if b == nil || n == 0 {
+		return 0
+	}
+	b.tokens = min(b.rate, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// throttle waits for the RateLimiter after bytes and lines are read.
+// It does not wait beyond the MaxDuration deadline.
+func (parser *Parser) throttle(nBytes, nLines int) {
+	if parser.RateLimiter == nil {
+		return
+	}
+	wait := parser.RateLimiter.reserve(nBytes, nLines)
+	if !parser.deadline.IsZero() {
+		wait = min(wait, time.Until(parser.deadline))
+	}
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// scannedLines returns the number of lines in the scanned buf for the RateLimiter.
+// Workers do not report rows per buffer, so they are counted here.
+func (parser *Parser) scannedLines(buf []byte, rows int, pool *workerPool) int {
+	if pool == nil || parser.RateLimiter == nil || parser.RateLimiter.lines == nil {
+		return rows
+	}
+	return bytes.Count(buf, []byte{'\n'})
+}
@@ -0,0 +1,64 @@
+package followparser
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(100, now)
+	if wait := b.reserve(now, 100); wait != 0 {
+		t.Fatalf("burst should not wait: %v", wait)
+	}
+	if wait := b.reserve(now, 50); wait != 500*time.Millisecond {
+		t.Fatalf("wait %v not match expect %v", wait, 500*time.Millisecond)
+	}
+	// the debt is paid after the wait
+	if wait := b.reserve(now.Add(time.Second), 50); wait != 0 {
+		t.Fatalf("wait %v not match expect 0", wait)
+	}
+	// tokens do not exceed the burst
+	if wait := b.reserve(now.Add(time.Hour), 150); wait != 500*time.Millisecond {
+		t.Fatalf("wait %v not match expect %v", wait, 500*time.Millisecond)
+	}
+	if wait := newTokenBucket(0, now).reserve(now, 1000); wait != 0 {
+		t.Fatalf("unlimited bucket should not wait: %v", wait)
+	}
+}
+
+func TestParseRateLimiter(t *testing.T) {
+	tmpdir := t.TempDir()
+	expected := ""
+	for i := 0; i < 300; i++ {
+		expected += fmt.Sprintf("msg msg %08d\n", i)
+	}
+	// two Parsers share 400 lines per second, 600 lines take 0.5s after the burst
+	limiter := NewRateLimiter(0, 400)
+	start := time.Now()
+	for _, name := range []string{"log1", "log2"} {
+		logFileName := filepath.Join(tmpdir, name)
+		if err := os.WriteFile(logFileName, []byte(expected), 0644); err != nil {
+			t.Fatal(err)
+		}
+		parser := &testParser{buf: bytes.NewBufferString("")}
+		fp := &Parser{WorkDir: tmpdir, Callback: parser, Silent: true, StartBufSize: 256, RateLimiter: limiter}
+		r, err := fp.Parse(name+"Pos", logFileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if read := parser.Slurp().String(); read != expected {
+			t.Fatalf("read '%s' not match expect '%s'", read, expected)
+		}
+		if len(r) != 1 || r[0].Rows != 300 {
+			t.Fatalf("unexpected result %v", r)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Fatalf("rate limiter did not wait: %v", elapsed)
+	}
+}
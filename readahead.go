@@ -0,0 +1,113 @@
+package followparser
+
+import (
+	"io"
+	"sync"
+)
+
+type readAheadChunk struct {
+	buf []byte
+	n   int
+	err error
+}
+
+// readAheadReader reads the next buffer in a goroutine while the current one is consumed
+type readAheadReader struct {
+	ch     chan readAheadChunk
+	free   chan []byte
+	done   chan struct{}
+	mu     sync.Mutex
+	closed bool
+	cur    []byte
+	curBuf []byte
+	err    error
+}
+
+// newReadAheadReader returns nil if the buffers do not fit in the buffer pool limit
+func newReadAheadReader(r io.Reader, size int) *readAheadReader {
+	a := defaultBufferPool.getSpare(size)
+	if a == nil {
+		return nil
+	}
+	b := defaultBufferPool.getSpare(size)
+	if b == nil {
+		defaultBufferPool.putSpare(a)
+		return nil
+	}
+	ra := &readAheadReader{
+		// sends never block, there are only two buffers
+		ch:   make(chan readAheadChunk, 2),
+		free: make(chan []byte, 2),
+		done: make(chan struct{}),
+	}
+	ra.free <- a
+	ra.free <- b
+	go ra.fill(r)
+	return ra
+}
+
+func (ra *readAheadReader) fill(r io.Reader) {
+	for {
+		var buf []byte
+		select {
+		case buf = <-ra.free:
+		case <-ra.done:
+			return
+		}
+		n, err := r.Read(buf)
+		ra.mu.Lock()
+		if ra.closed {
+			// Close did not wait for this Read
+			ra.mu.Unlock()
+			defaultBufferPool.putSpare(buf)
+			return
+		}
+		ra.ch <- readAheadChunk{buf: buf, n: n, err: err}
+		ra.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (ra *readAheadReader) Read(p []byte) (int, error) {
+	for len(ra.cur) == 0 {
+		if ra.curBuf != nil {
+			ra.free <- ra.curBuf
+			ra.curBuf = nil
+		}
+		if ra.err != nil {
+			return 0, ra.err
+		}
+		c := <-ra.ch
+		ra.cur = c.buf[:c.n]
+		ra.curBuf = c.buf
+		ra.err = c.err
+	}
+	n := copy(p, ra.cur)
+	ra.cur = ra.cur[n:]
+	return n, nil
+}
+
+// Close stops the reading goroutine and releases the buffers.
+// It does not wait for a Read blocked on a pipe, the goroutine releases its buffer when the Read returns.
+func (ra *readAheadReader) Close() error {
+	ra.mu.Lock()
+	ra.closed = true
+	close(ra.done)
+	ra.mu.Unlock()
+	if ra.curBuf != nil {
+		defaultBufferPool.putSpare(ra.curBuf)
+		ra.curBuf = nil
+	}
+	for {
+		select {
+		case c := <-ra.ch:
+			defaultBufferPool.putSpare(c.buf)
+		case buf := <-ra.free:
+			defaultBufferPool.putSpare(buf)
+		default:
+			return nil
+		}
+	}
+}
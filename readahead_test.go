@@ -0,0 +1,101 @@
+package followparser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestReadAheadReader(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789abcdef\n", 100))
+	ra := newReadAheadReader(iotest.HalfReader(bytes.NewReader(content)), 64)
+	defer ra.Close()
+	if err := iotest.TestReader(ra, content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseReadAhead(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	expected := ""
+	for i := 0; i < 500; i++ {
+		expected += fmt.Sprintf("msg msg %08d\n", i)
+	}
+	longLine := strings.Repeat("A", 200) + "\n"
+	if err := os.WriteFile(logFileName, []byte(expected+longLine+"partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	parser := &testParser{buf: bytes.NewBufferString("")}
+	fp := &Parser{WorkDir: tmpdir, Callback: parser, Silent: true, ReadAhead: true, StartBufSize: 64}
+	r, err := fp.Parse("logPosReadAhead", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := parser.Slurp().String(); out != expected+longLine {
+		t.Fatalf("read ahead length %d not match expect %d", len(out), len(expected+longLine))
+	}
+	if len(r) != 1 || r[0].Rows != 501 || r[0].EndPos != int64(len(expected+longLine)) {
+		t.Fatalf("unexpected result %v", r)
+	}
+}
+
+func TestReadAheadReaderClose(t *testing.T) {
+	// Close must not wait for a Read blocked on a pipe after an early stop
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	ra := newReadAheadReader(pr, 64)
+	closed := make(chan struct{})
+	go func() {
+		ra.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close must not wait for the blocked Read")
+	}
+}
+
+func TestParseReadAheadLimit(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	expected := ""
+	for i := 0; i < 500; i++ {
+		expected += fmt.Sprintf("msg msg %08d\n", i)
+	}
+	if err := os.WriteFile(logFileName, []byte(expected), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer SetBufferPoolLimit(0)
+
+	// the read ahead buffers fit in the limit or are skipped
+	for _, limit := range []int64{64, 128, 192} {
+		SetBufferPoolLimit(limit)
+		parser := &testParser{buf: bytes.NewBufferString("")}
+		fp := &Parser{WorkDir: tmpdir, Callback: parser, Silent: true, ReadAhead: true, StartBufSize: 64}
+		parsed := make(chan error, 1)
+		go func() {
+			_, err := fp.Parse(fmt.Sprintf("logPosReadAhead%d", limit), logFileName)
+			parsed <- err
+		}()
+		select {
+		case err := <-parsed:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Parse with the limit %d must not wait for its own buffers", limit)
+		}
+		if out := parser.Slurp().String(); out != expected {
+			t.Fatalf("read ahead length %d not match expect %d with the limit %d", len(out), len(expected), limit)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package followparser
+
+// Record is a decoded line whose fields are looked up by name.
+// LTSVRecord, JSONRecord, LogFormatRecord and RegexpRecord implement it, so aggregations can be written once for any of them.
+type Record interface {
+	Get(name string) ([]byte, bool)
+}
+
+var (
+	_ Record = (*LTSVRecord)(nil)
+	_ Record = (*JSONRecord)(nil)
+	_ Record = (*LogFormatRecord)(nil)
+	_ Record = (*RegexpRecord)(nil)
+)
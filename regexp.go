@@ -0,0 +1,77 @@
+package followparser
+
+import (
+	"errors"
+	"regexp"
+)
+
+// ErrRegexpMismatch is returned when a line does not match the regexp
+var ErrRegexpMismatch = errors.New("regexp: line does not match")
+
+// RegexpRecord is a line split into the named groups of a regexp.
+// Values point into the line buffer and are only valid during the handler call.
+type RegexpRecord struct {
+	names  []string
+	values [][]byte
+}
+
+// Len returns the number of groups including the unnamed ones
+func (r *RegexpRecord) Len() int {
+	return len(r.values)
+}
+
+// Name returns the name of the i-th group, empty if it is unnamed
+func (r *RegexpRecord) Name(i int) string {
+	return r.names[i]
+}
+
+// Value returns the value of the i-th group, nil if it did not participate in the match
+func (r *RegexpRecord) Value(i int) []byte {
+	return r.values[i]
+}
+
+// Get returns the value of the named group. ok is false if the regexp has no such group.
+func (r *RegexpRecord) Get(name string) ([]byte, bool) {
+	for i, n := range r.names {
+		if n != "" && n == name {
+			return r.values[i], true
+		}
+	}
+	return nil, false
+}
+
+// RegexpCallback is a Callback that matches each line with Regexp and passes the named groups to Handler.
+// The record is reused for every line, so Parser.Workers must not be set.
+type RegexpCallback struct {
+	Regexp *regexp.Regexp
+	// Handler is called for each matched line
+	Handler func(r *RegexpRecord) error
+	// OnFinish is called from Finish if set
+	OnFinish func(duration float64)
+	record   RegexpRecord
+}
+
+// Parse matches b and calls Handler. Empty lines are skipped.
+func (c *RegexpCallback) Parse(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	// group 0 is the whole match
+	m := c.Regexp.FindSubmatch(b)
+	if m == nil {
+		return ErrRegexpMismatch
+	}
+	c.record.names = c.Regexp.SubexpNames()[1:]
+	c.record.values = m[1:]
+	if c.Handler == nil {
+		return nil
+	}
+	return c.Handler(&c.record)
+}
+
+// Finish calls OnFinish
+func (c *RegexpCallback) Finish(duration float64) {
+	if c.OnFinish != nil {
+		c.OnFinish(duration)
+	}
+}
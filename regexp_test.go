@@ -0,0 +1,47 @@
+package followparser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestRegexpCallback(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "app.log")
+	content := "2026-10-18T10:00:00Z ERROR disk full\ngarbage\n2026-10-18T10:00:01Z INFO started\n"
+	if err := os.WriteFile(logFileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	levels := []string{}
+	cb := &RegexpCallback{
+		Regexp: regexp.MustCompile(`^(?P<time>\S+) (?P<level>[A-Z]+) (.*)$`),
+		Handler: func(r *RegexpRecord) error {
+			level, _ := r.Get("level")
+			levels = append(levels, string(level))
+			if _, ok := r.Get(""); ok {
+				t.Error("unnamed groups must not be found")
+			}
+			if r.Len() != 3 || r.Name(1) != "level" {
+				t.Errorf("unexpected groups %d %s", r.Len(), r.Name(1))
+			}
+			return nil
+		},
+	}
+	if err := cb.Parse([]byte("garbage")); !errors.Is(err, ErrRegexpMismatch) {
+		t.Errorf("must be ErrRegexpMismatch %v", err)
+	}
+	fp := &Parser{WorkDir: tmpdir, Callback: cb, Silent: true}
+	r, err := fp.Parse("regexpPos", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 1 || r[0].Rows != 3 || r[0].Errors != 1 {
+		t.Fatalf("result must be 1 file with 3 rows and 1 error %v", r)
+	}
+	if len(levels) != 2 || levels[0] != "ERROR" || levels[1] != "INFO" {
+		t.Errorf("unexpected levels %v", levels)
+	}
+}
@@ -0,0 +1,192 @@
+package followparser
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ReplayRange restricts the lines passed to the Callback by Replay. Zero values are not restricted.
+type ReplayRange struct {
+	// StartPos and EndPos are byte offsets in each file. Offsets of a compressed file are in the decompressed data.
+	// StartPos should be at the start of a line. Lines end at the last newline before EndPos,
+	// a line crossing EndPos is not passed.
+	StartPos int64
+	EndPos   int64
+	// Since and Until select lines with TimeOf. Lines without a time follow the previous line.
+	// The lines of a file after the first line past Until are not read.
+	Since  time.Time
+	Until  time.Time
+	TimeOf TimeFunc
+}
+
+// Replay passes the lines of files to the Callback again, within r.
+// Files compressed with gzip (.gz) or bzip2 (.bz2) are decompressed. Each file is read to the end
+// including the final partial line, except for a compressed file with r.EndPos as its size is unknown.
+// The posfile is neither read nor written.
+// It returns a Parsed for each file, Rows is the number of lines passed to the Callback.
+func (parser *Parser) Replay(files []string, r ReplayRange) ([]Parsed, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	parser.setDefaults(files[0])
+	if (!r.Since.IsZero() || !r.Until.IsZero()) && r.TimeOf == nil {
+		return nil, fmt.Errorf("failed to replay :TimeOf is required for a time range")
+	}
+	parser.posFile = nil
+	parser.stopped = false
+	parser.deadline = time.Time{}
+	if parser.MaxDuration > 0 {
+		parser.deadline = time.Now().Add(parser.MaxDuration)
+	}
+	cb := parser.Callback
+	defer func() {
+		parser.Callback = cb
+	}()
+	result := make([]Parsed, 0, len(files))
+	for _, file := range files {
+		parsed, err := parser.replayFile(cb, file, r)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, *parsed)
+		if parser.stopped {
+			break
+		}
+	}
+	cb.Finish(0)
+	return result, nil
+}
+
+func (parser *Parser) replayFile(cb Callback, file string, r ReplayRange) (*Parsed, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file :%v", err)
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat log file :%v", err)
+	}
+	startPos := r.StartPos
+	var rd io.Reader
+	compressed := true
+	switch filepath.Ext(file) {
+	case ".gz":
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip file :%v", err)
+		}
+		defer zr.Close()
+		rd = zr
+	case ".bz2":
+		rd = bzip2.NewReader(f)
+	default:
+		if !r.Since.IsZero() {
+			// skip the lines before Since by a binary search
+			s := &timeSearcher{f: f, size: st.Size(), maxLine: parser.MaxBufSize, timeOf: r.TimeOf}
+			offset, _, err := s.search(r.Since)
+			if err != nil {
+				return nil, err
+			}
+			startPos = max(startPos, offset)
+		}
+		if _, err := f.Seek(startPos, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to seek log file :%v", err)
+		}
+		rd = f
+		compressed = false
+	}
+	if compressed && startPos > 0 {
+		if _, err := io.CopyN(io.Discard, rd, startPos); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read log file :%v", err)
+		}
+	}
+	// the line at EndPos is left like the partial line of the newest file
+	partial := false
+	if r.EndPos > 0 {
+		rd = io.LimitReader(rd, max(0, r.EndPos-startPos))
+		partial = compressed || r.EndPos < st.Size()
+	}
+
+	parser.Callback = cb
+	var filter *timeRangeCallback
+	if !r.Since.IsZero() || !r.Until.IsZero() {
+		// lines are filtered in order
+		workers := parser.Workers
+		defer func() {
+			parser.Workers = workers
+		}()
+		parser.Workers = 0
+		filter = &timeRangeCallback{cb: cb, since: r.Since, until: r.Until, timeOf: r.TimeOf, in: r.Since.IsZero()}
+		filter.meta, _ = cb.(MetaCallback)
+		parser.Callback = filter
+	}
+	parser.scanFileName = file
+	parser.scanBase = startPos
+	parser.scanErrors = 0
+	rows, read, err := parser.scanFile(rd, partial)
+	if err == ErrStop {
+		if filter == nil || !filter.passed {
+			parser.stopped = true
+		}
+	} else if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("something wrong in parse log :%v", err)
+	}
+	if filter != nil {
+		rows -= filter.skipped
+	}
+	return &Parsed{
+		FileName: file,
+		Size:     st.Size(),
+		StartPos: startPos,
+		EndPos:   startPos + read,
+		Rows:     rows,
+		Errors:   parser.scanErrors,
+
+		Interrupted: parser.stopped,
+	}, nil
+}
+
+// timeRangeCallback passes the lines between since and until to cb
+type timeRangeCallback struct {
+	cb      Callback
+	meta    MetaCallback
+	since   time.Time
+	until   time.Time
+	timeOf  TimeFunc
+	in      bool
+	skipped int
+	// passed is true once a line past until is found
+	passed bool
+}
+
+func (c *timeRangeCallback) Parse(b []byte) error {
+	return c.ParseMeta(b, Meta{})
+}
+
+func (c *timeRangeCallback) ParseMeta(b []byte, meta Meta) error {
+	if t, ok := c.timeOf(b); ok {
+		if !c.until.IsZero() && t.After(c.until) {
+			c.passed = true
+			c.skipped++
+			return ErrStop
+		}
+		c.in = c.since.IsZero() || !t.Before(c.since)
+	}
+	if !c.in {
+		c.skipped++
+		return nil
+	}
+	if c.meta != nil {
+		return c.meta.ParseMeta(b, meta)
+	}
+	return c.cb.Parse(b)
+}
+
+func (c *timeRangeCallback) Finish(_ float64) {
+}
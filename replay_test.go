@@ -0,0 +1,144 @@
+package followparser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeGzip(t *testing.T, name string, content []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayByteRange(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	appendFile(t, logFileName, "line1\nline2\nline3\npartial")
+	writeGzip(t, logFileName+".1.gz", []byte("old01\nold02\nold03\n"))
+
+	parser := &testParser{buf: bytes.NewBufferString("")}
+	fp := &Parser{WorkDir: tmpdir, Callback: parser, Silent: true}
+	r, err := fp.Replay([]string{logFileName + ".1.gz", logFileName}, ReplayRange{StartPos: 6, EndPos: 18})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "old02\nold03\nline2\nline3\n"
+	if read := parser.Slurp().String(); read != expected {
+		t.Fatalf("read '%s' not match expect '%s'", read, expected)
+	}
+	if len(r) != 2 || r[0].StartPos != 6 || r[0].EndPos != 18 || r[0].Rows != 2 || r[1].StartPos != 6 || r[1].EndPos != 18 || r[1].Rows != 2 {
+		t.Fatalf("unexpected result %v", r)
+	}
+	parser.buf.Reset()
+
+	// the whole file including the final partial line
+	if _, err := fp.Replay([]string{logFileName}, ReplayRange{}); err != nil {
+		t.Fatal(err)
+	}
+	if read := parser.Slurp().String(); read != "line1\nline2\nline3\npartial\n" {
+		t.Fatalf("read '%s' not match expect '%s'", read, "line1\nline2\nline3\npartial\n")
+	}
+	parser.buf.Reset()
+
+	// a line crossing EndPos is not passed
+	r, err = fp.Replay([]string{logFileName}, ReplayRange{EndPos: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read := parser.Slurp().String(); read != "line1\n" {
+		t.Fatalf("read '%s' not match expect '%s'", read, "line1\n")
+	}
+	if len(r) != 1 || r[0].EndPos != 6 || r[0].Rows != 1 {
+		t.Fatalf("unexpected result %v", r)
+	}
+	parser.buf.Reset()
+
+	// EndPos past the end keeps the final partial line
+	if _, err := fp.Replay([]string{logFileName}, ReplayRange{StartPos: 18, EndPos: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if read := parser.Slurp().String(); read != "partial\n" {
+		t.Fatalf("read '%s' not match expect '%s'", read, "partial\n")
+	}
+
+	// Replay never writes the posfile
+	if entries, _ := filepath.Glob(filepath.Join(tmpdir, "*-*")); len(entries) != 0 {
+		t.Fatalf("posfile should not be written: %v", entries)
+	}
+}
+
+func TestReplayTimeRange(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	base := seekTestTime(t, "18/Oct/2026:00:00:00 +0000")
+	writeTimedLog(t, logFileName, base, 40000, nil)
+	content, err := os.ReadFile(logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeGzip(t, logFileName+".gz", content)
+
+	for _, name := range []string{logFileName, logFileName + ".gz"} {
+		parser := &testParser{buf: bytes.NewBufferString("")}
+		fp := &Parser{WorkDir: tmpdir, Callback: parser, Silent: true, Workers: 4}
+		r, err := fp.Replay([]string{name}, ReplayRange{
+			Since:  base.Add(20000 * time.Second),
+			Until:  base.Add(20019 * time.Second),
+			TimeOf: seekTestTimeFunc,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSuffix(parser.Slurp().String(), "\n"), "\n")
+		// 20 lines and 2 continued lines without a time
+		if len(lines) != 22 || !strings.Contains(lines[0], "GET /20000 ") || !strings.Contains(lines[21], "GET /20019 ") {
+			t.Fatalf("%s: unexpected lines %d %q", name, len(lines), lines)
+		}
+		if len(r) != 1 || r[0].Rows != 22 || r[0].Interrupted {
+			t.Fatalf("%s: unexpected result %v", name, r)
+		}
+	}
+
+	fp := &Parser{WorkDir: tmpdir, Silent: true}
+	if _, err := fp.Replay([]string{logFileName}, ReplayRange{Since: base}); err == nil {
+		t.Fatal("a time range without TimeOf should fail")
+	}
+}
+
+func TestReplayBzip2(t *testing.T) {
+	bzip2, err := exec.LookPath("bzip2")
+	if err != nil {
+		t.Skip("bzip2 is not installed")
+	}
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log.1")
+	appendFile(t, logFileName, "old1\nold2\n")
+	if err := exec.Command(bzip2, logFileName).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	parser := &testParser{buf: bytes.NewBufferString("")}
+	fp := &Parser{WorkDir: tmpdir, Callback: parser, Silent: true}
+	if _, err := fp.Replay([]string{logFileName + ".bz2"}, ReplayRange{}); err != nil {
+		t.Fatal(err)
+	}
+	if read := parser.Slurp().String(); read != "old1\nold2\n" {
+		t.Fatalf("read '%s' not match expect '%s'", read, "old1\nold2\n")
+	}
+}
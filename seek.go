@@ -0,0 +1,270 @@
+package followparser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// seekFixupSize is the number of bytes scanned linearly before the result of the binary search,
+// so that a line with a slightly earlier timestamp than its neighbors is still found
+const seekFixupSize = 64 * 1024
+
+// TimeFunc returns the time of a line. ok is false if the line has no time.
+type TimeFunc func(line []byte) (t time.Time, ok bool)
+
+// RegexpTimeFunc returns a TimeFunc that parses the first submatch of re with layout
+func RegexpTimeFunc(re *regexp.Regexp, layout string) TimeFunc {
+	return func(line []byte) (time.Time, bool) {
+		m := re.FindSubmatch(line)
+		if len(m) < 2 {
+			return time.Time{}, false
+		}
+		t, err := time.Parse(layout, string(m[1]))
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+}
+
+// SeekTime writes the posfile of posFileName to the first line at or after t.
+// logFile is binary searched, and if its first line is already at or after t, the previous rotated file in ArchiveDir too.
+// Timestamps are expected to be mostly increasing, lines out of order are found within a bounded window.
+// Lines without a time are skipped. If there is no such line, the posfile is set to the end of logFile.
+// It returns the position written.
+func (parser *Parser) SeekTime(posFileName, logFile string, t time.Time, timeOf TimeFunc) (*Position, error) {
+	parser.setDefaults(logFile)
+	fstat, err := fileStat(logFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inode from log file :%v", err)
+	}
+	fileName := logFile
+	offset, _, err := parser.searchTime(logFile, fstat.Size, t, timeOf)
+	if err != nil {
+		return nil, err
+	}
+	if offset == 0 {
+		// the lines before t may be in the previous file
+		if archive, err := parser.previousArchive(logFile, fstat); err == nil {
+			astat, err := fileStat(archive)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get inode from rotated file :%v", err)
+			}
+			aoffset, found, err := parser.searchTime(archive, astat.Size, t, timeOf)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				fileName, fstat, offset = archive, astat, aoffset
+			}
+		}
+	}
+	p := &Position{Path: parser.posFilePath(posFileName)}
+	err = newPosFile(p.Path).write(offset, fstat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update pos file :%v", err)
+	}
+	p.Exists = true
+	p.Offset = offset
+	p.Inode = fstat.Inode
+	p.Dev = fstat.Dev
+	p.CommittedAt = time.Now()
+	p.FileName = fileName
+	return p, nil
+}
+
+// previousArchive returns the most recently modified rotated file of logFile in ArchiveDir.
+// Rotated files are the regular files named after logFile with a suffix such as ".1" or "-20060102".
+// Compressed files are skipped.
+func (parser *Parser) previousArchive(logFile string, fstat *fStat) (string, error) {
+	entries, err := os.ReadDir(parser.ArchiveDir)
+	if err != nil {
+		return "", err
+	}
+	base := filepath.Base(logFile)
+	var last string
+	var lastMod time.Time
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !isRotatedName(e.Name(), base) || isCompressed(e.Name()) {
+			continue
+		}
+		name := filepath.Join(parser.ArchiveDir, e.Name())
+		s, err := fileStat(name)
+		if err != nil || (s.Inode == fstat.Inode && s.Dev == fstat.Dev) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if last == "" || info.ModTime().After(lastMod) {
+			last, lastMod = name, info.ModTime()
+		}
+	}
+	if last == "" {
+		return "", fmt.Errorf("there is no rotated file of %s in %s", base, parser.ArchiveDir)
+	}
+	return last, nil
+}
+
+// isRotatedName reports whether name is base with a rotation suffix
+func isRotatedName(name, base string) bool {
+	suffix, ok := strings.CutPrefix(name, base)
+	return ok && len(suffix) > 1 && strings.ContainsRune(".-_", rune(suffix[0]))
+}
+
+// isCompressed reports whether name has the extension of a compressed file
+func isCompressed(name string) bool {
+	switch filepath.Ext(name) {
+	case ".gz", ".bz2", ".xz", ".zst", ".lz4", ".zip":
+		return true
+	}
+	return false
+}
+
+// searchTime returns the offset of the first line of logFile at or after t up to size,
+// and whether such a line is found. It returns the end of the last complete line if not.
+func (parser *Parser) searchTime(logFile string, size int64, t time.Time, timeOf TimeFunc) (int64, bool, error) {
+	f, err := os.Open(logFile)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to open log file :%v", err)
+	}
+	defer f.Close()
+	s := &timeSearcher{f: f, size: size, maxLine: parser.MaxBufSize, timeOf: timeOf}
+	return s.search(t)
+}
+
+// timeSearcher binary searches lines of a file by time
+type timeSearcher struct {
+	f       io.ReaderAt
+	size    int64
+	maxLine int
+	timeOf  TimeFunc
+}
+
+func (s *timeSearcher) search(t time.Time) (int64, bool, error) {
+	// the first line at or after t is after lo
+	lo, hi := int64(0), s.size
+	for hi-lo > seekFixupSize {
+		mid := lo + (hi-lo)/2
+		start, lt, ok, err := s.firstTimedLine(mid, hi)
+		if err != nil {
+			return 0, false, err
+		}
+		if ok && lt.Before(t) {
+			lo = start
+		} else {
+			hi = mid
+		}
+	}
+	start, err := s.lineStart(max(0, lo-seekFixupSize))
+	if err != nil {
+		return 0, false, err
+	}
+	return seekTimeLinear(io.NewSectionReader(s.f, start, s.size-start), start, s.maxLine, t, s.timeOf)
+}
+
+// lineStart returns the offset of the first line starting at or after off
+func (s *timeSearcher) lineStart(off int64) (int64, error) {
+	if off == 0 {
+		return 0, nil
+	}
+	lr := newLineReader(io.NewSectionReader(s.f, off-1, s.size-off+1), off-1, s.maxLine)
+	_, _, err := lr.next()
+	if err == io.EOF {
+		return s.size, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return lr.offset, nil
+}
+
+// firstTimedLine returns the first line with a time starting at or after off and before limit
+func (s *timeSearcher) firstTimedLine(off, limit int64) (int64, time.Time, bool, error) {
+	start, err := s.lineStart(off)
+	if err != nil {
+		return 0, time.Time{}, false, err
+	}
+	lr := newLineReader(io.NewSectionReader(s.f, start, s.size-start), start, s.maxLine)
+	for lr.offset < limit {
+		line, offset, err := lr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, time.Time{}, false, err
+		}
+		if lt, ok := s.timeOf(line[:len(line)-1]); ok {
+			return offset, lt, true, nil
+		}
+	}
+	return 0, time.Time{}, false, nil
+}
+
+// seekTimeLinear returns the offset of the first complete line at or after t in r, which starts at base,
+// and whether such a line is found. It returns the end of the last complete line if not.
+func seekTimeLinear(r io.Reader, base int64, maxLine int, t time.Time, timeOf TimeFunc) (int64, bool, error) {
+	lr := newLineReader(r, base, maxLine)
+	for {
+		line, offset, err := lr.next()
+		if err == io.EOF {
+			// the final partial line is not complete yet
+			return offset, false, nil
+		}
+		if err != nil {
+			return 0, false, err
+		}
+		if lt, ok := timeOf(line[:len(line)-1]); ok && !lt.Before(t) {
+			return offset, true, nil
+		}
+	}
+}
+
+// lineReader reads complete lines of up to maxLine bytes
+type lineReader struct {
+	br      *bufio.Reader
+	maxLine int
+	// long holds a line longer than the buffer of br
+	long []byte
+	// offset is the offset of the next line
+	offset int64
+}
+
+func newLineReader(r io.Reader, offset int64, maxLine int) *lineReader {
+	return &lineReader{br: bufio.NewReaderSize(r, 64*1024), maxLine: maxLine, offset: offset}
+}
+
+// next returns the next line including the newline and its offset.
+// It returns io.EOF with the offset of the final partial line.
+func (lr *lineReader) next() ([]byte, int64, error) {
+	lr.long = lr.long[:0]
+	for {
+		line, err := lr.br.ReadSlice('\n')
+		if len(lr.long) > 0 || err == bufio.ErrBufferFull {
+			if len(lr.long)+len(line) > lr.maxLine {
+				return nil, 0, ErrTokenTooLong
+			}
+			lr.long = append(lr.long, line...)
+			if err == bufio.ErrBufferFull {
+				continue
+			}
+			line = lr.long
+		}
+		if err == io.EOF {
+			return nil, lr.offset, io.EOF
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read log file :%v", err)
+		}
+		offset := lr.offset
+		lr.offset += int64(len(line))
+		return line, offset, nil
+	}
+}
@@ -0,0 +1,192 @@
+package followparser
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const seekTestLayout = "02/Jan/2006:15:04:05 -0700"
+
+var seekTestTimeFunc = RegexpTimeFunc(regexp.MustCompile(`\[([^\]]+)\]`), seekTestLayout)
+
+func seekTestTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	tm, err := time.Parse(seekTestLayout, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tm
+}
+
+func TestSeekTime(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	lines := []string{
+		"127.0.0.1 [18/Oct/2026:10:00:00 +0000] a\n",
+		"no time\n",
+		"127.0.0.1 [18/Oct/2026:10:00:05 +0000] " + strings.Repeat("b", 100) + "\n",
+		"127.0.0.1 [18/Oct/2026:10:00:10 +0000] c\n",
+	}
+	appendFile(t, logFileName, strings.Join(lines, "")+"partial [18/Oct/2026:10:00:20 +0000]")
+	offsetOf := func(i int) int64 {
+		return int64(len(strings.Join(lines[:i], "")))
+	}
+
+	fp := &Parser{WorkDir: tmpdir, Silent: true, MaxBufSize: 1024}
+	tests := []struct {
+		at     string
+		expect int64
+	}{
+		{"18/Oct/2026:09:00:00 +0000", 0},
+		{"18/Oct/2026:10:00:00 +0000", 0},
+		{"18/Oct/2026:10:00:01 +0000", offsetOf(2)},
+		{"18/Oct/2026:19:00:06 +0900", offsetOf(3)},
+		{"18/Oct/2026:10:00:11 +0000", offsetOf(4)},
+	}
+	for _, tt := range tests {
+		sp, err := fp.SeekTime("logPosSeek", logFileName, seekTestTime(t, tt.at), seekTestTimeFunc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sp.Offset != tt.expect || sp.FileName != logFileName {
+			t.Fatalf("seek %s offset %d not match expect %d", tt.at, sp.Offset, tt.expect)
+		}
+		p, err := fp.Position("logPosSeek", logFileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Offset != tt.expect {
+			t.Fatalf("pos %d not match expect %d", p.Offset, tt.expect)
+		}
+	}
+}
+
+func TestSeekTimeLongLine(t *testing.T) {
+	// a line longer than the read buffer is still checked
+	long := "[18/Oct/2026:10:00:05 +0000] " + strings.Repeat("x", 100*1024) + "\n"
+	content := "[18/Oct/2026:10:00:00 +0000] a\n" + long + "[18/Oct/2026:10:00:10 +0000] c\n"
+	offset, found, err := seekTimeLinear(strings.NewReader(content), 0, 1024*1024, seekTestTime(t, "18/Oct/2026:10:00:01 +0000"), seekTestTimeFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || offset != 31 {
+		t.Fatalf("offset %d not match expect 31", offset)
+	}
+	if _, _, err := seekTimeLinear(strings.NewReader(content), 0, 1024, seekTestTime(t, "18/Oct/2026:10:00:01 +0000"), seekTestTimeFunc); err != ErrTokenTooLong {
+		t.Fatalf("err %v not match expect %v", err, ErrTokenTooLong)
+	}
+}
+
+// writeTimedLog writes n lines, one second apart from base, and returns the offsets of the lines
+func writeTimedLog(t *testing.T, name string, base time.Time, n int, shuffle func(i int) time.Duration) []int64 {
+	t.Helper()
+	var sb strings.Builder
+	offsets := make([]int64, 0, n)
+	for i := 0; i < n; i++ {
+		offsets = append(offsets, int64(sb.Len()))
+		tm := base.Add(time.Duration(i) * time.Second)
+		if shuffle != nil {
+			tm = tm.Add(shuffle(i))
+		}
+		fmt.Fprintf(&sb, "127.0.0.1 - - [%s] \"GET /%d HTTP/1.1\" 200\n", tm.Format(seekTestLayout), i)
+		if i%10 == 0 {
+			sb.WriteString("continued line without a time\n")
+		}
+	}
+	appendFile(t, name, sb.String())
+	return offsets
+}
+
+func TestSeekTimeBinarySearch(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	base := seekTestTime(t, "18/Oct/2026:00:00:00 +0000")
+	// about 2MB, the binary search narrows it down before the linear scan
+	offsets := writeTimedLog(t, logFileName, base, 40000, nil)
+
+	fp := &Parser{WorkDir: tmpdir, Silent: true}
+	for _, i := range []int{0, 1, 12345, 20000, 39999} {
+		sp, err := fp.SeekTime("logPosBinary", logFileName, base.Add(time.Duration(i)*time.Second), seekTestTimeFunc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sp.Offset != offsets[i] {
+			t.Fatalf("seek line %d offset %d not match expect %d", i, sp.Offset, offsets[i])
+		}
+	}
+	st, _ := os.Stat(logFileName)
+	sp, err := fp.SeekTime("logPosBinary", logFileName, base.Add(time.Hour*24), seekTestTimeFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sp.Offset != st.Size() {
+		t.Fatalf("offset %d not match expect the end %d", sp.Offset, st.Size())
+	}
+}
+
+func TestSeekTimeNonMonotonic(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	base := seekTestTime(t, "18/Oct/2026:00:00:00 +0000")
+	// every other line is logged 3 seconds late
+	offsets := writeTimedLog(t, logFileName, base, 40000, func(i int) time.Duration {
+		if i%2 == 1 {
+			return 3 * time.Second
+		}
+		return 0
+	})
+
+	fp := &Parser{WorkDir: tmpdir, Silent: true}
+	sp, err := fp.SeekTime("logPosNonMonotonic", logFileName, base.Add(20000*time.Second), seekTestTimeFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// line 19997 has the time of 20000
+	if sp.Offset != offsets[19997] {
+		t.Fatalf("offset %d not match expect %d", sp.Offset, offsets[19997])
+	}
+}
+
+func TestSeekTimeRotated(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	base := seekTestTime(t, "18/Oct/2026:00:00:00 +0000")
+	offsets := writeTimedLog(t, logFileName+".1", base, 100, nil)
+	writeTimedLog(t, logFileName+".2.gz", base.Add(-time.Hour), 10, nil)
+	writeTimedLog(t, logFileName, base.Add(100*time.Second), 100, nil)
+
+	fp := &Parser{WorkDir: tmpdir, Silent: true}
+	sp, err := fp.SeekTime("logPosRotated", logFileName, base.Add(50*time.Second), seekTestTimeFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sp.FileName != logFileName+".1" || sp.Offset != offsets[50] {
+		t.Fatalf("unexpected position %+v", sp)
+	}
+
+	// Parse continues in the rotated file and then reads the current file
+	parser := &testParser{buf: bytes.NewBufferString("")}
+	fp.Callback = parser
+	r, err := fp.Parse("logPosRotated", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 2 || r[0].Rows != 50+5 || r[1].Rows != 100+10 {
+		t.Fatalf("unexpected result %v", r)
+	}
+
+	// lines before the previous file start from its head
+	sp, err = fp.SeekTime("logPosRotated", logFileName, base.Add(-time.Minute), seekTestTimeFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sp.FileName != logFileName+".1" || sp.Offset != 0 {
+		t.Fatalf("unexpected position %+v", sp)
+	}
+}
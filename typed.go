@@ -0,0 +1,60 @@
+package followparser
+
+import "sync/atomic"
+
+// Decoder converts a line into T
+type Decoder[T any] func(b []byte) (T, error)
+
+// Handler receives a decoded value with the position it was read from
+type Handler[T any] func(v T, meta Meta) error
+
+// TypedParser is a Parser that decodes each line into T before passing it to Handler.
+// Lines that fail to decode are not passed to Handler. They are counted in Parsed.Errors
+// together with the errors of Handler, and alone by DecodeErrors.
+type TypedParser[T any] struct {
+	Parser
+	Decoder Decoder[T]
+	Handler Handler[T]
+	// OnFinish is called once parsing is completed if set
+	OnFinish     func(duration float64)
+	decodeErrors atomic.Int64
+}
+
+// DecodeErrors returns the number of lines that failed to decode in the last Parse
+func (tp *TypedParser[T]) DecodeErrors() int64 {
+	return tp.decodeErrors.Load()
+}
+
+// Parse parses logFile like Parser.Parse with the posfile and rotation semantics of Parser.
+func (tp *TypedParser[T]) Parse(posFileName, logFile string) ([]Parsed, error) {
+	tp.decodeErrors.Store(0)
+	tp.Parser.Callback = &typedCallback[T]{tp: tp}
+	return tp.Parser.Parse(posFileName, logFile)
+}
+
+type typedCallback[T any] struct {
+	tp *TypedParser[T]
+}
+
+func (c *typedCallback[T]) Parse(b []byte) error {
+	return c.ParseMeta(b, Meta{})
+}
+
+func (c *typedCallback[T]) ParseMeta(b []byte, meta Meta) error {
+	v, err := c.tp.Decoder(b)
+	if err != nil {
+		// Workers may decode lines concurrently
+		c.tp.decodeErrors.Add(1)
+		return err
+	}
+	if c.tp.Handler == nil {
+		return nil
+	}
+	return c.tp.Handler(v, meta)
+}
+
+func (c *typedCallback[T]) Finish(duration float64) {
+	if c.tp.OnFinish != nil {
+		c.tp.OnFinish(duration)
+	}
+}
@@ -0,0 +1,80 @@
+package followparser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestTypedParser(t *testing.T) {
+	tmpdir := t.TempDir()
+	logFileName := filepath.Join(tmpdir, "log")
+	if err := os.WriteFile(logFileName, []byte("1\n2\nx\n3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := 0
+	offsets := []int64{}
+	finished := false
+	tp := &TypedParser[int]{
+		Parser: Parser{WorkDir: tmpdir, Silent: true},
+		Decoder: func(b []byte) (int, error) {
+			return strconv.Atoi(string(b))
+		},
+		Handler: func(v int, meta Meta) error {
+			if v == 2 {
+				return fmt.Errorf("handler error")
+			}
+			if meta.FileName != logFileName {
+				t.Errorf("meta.FileName %s must be %s", meta.FileName, logFileName)
+			}
+			sum += v
+			offsets = append(offsets, meta.Offset)
+			return nil
+		},
+		OnFinish: func(_ float64) {
+			finished = true
+		},
+	}
+	r, err := tp.Parse("typedPos", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 1 || r[0].Rows != 4 || r[0].Errors != 2 {
+		t.Fatalf("result must be 1 file with 4 rows and 2 errors %v", r)
+	}
+	if tp.DecodeErrors() != 1 {
+		t.Errorf("decode errors must be 1: %d", tp.DecodeErrors())
+	}
+	if sum != 4 {
+		t.Errorf("sum must be 4: %d", sum)
+	}
+	if fmt.Sprint(offsets) != "[0 6]" {
+		t.Errorf("unexpected offsets %v", offsets)
+	}
+	if !finished {
+		t.Errorf("OnFinish must be called")
+	}
+
+	// resume from the posfile
+	f, err := os.OpenFile(logFileName, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("10\n")
+	f.Close()
+	sum = 0
+	offsets = offsets[:0]
+	r, err = tp.Parse("typedPos", logFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 1 || r[0].Rows != 1 || r[0].Errors != 0 || tp.DecodeErrors() != 0 {
+		t.Fatalf("result must be 1 file with 1 row %v", r)
+	}
+	if sum != 10 || fmt.Sprint(offsets) != "[8]" {
+		t.Errorf("unexpected sum %d and offsets %v", sum, offsets)
+	}
+}